@@ -0,0 +1,104 @@
+package check
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/internal/linkcheck"
+)
+
+type command struct {
+	binPath    string
+	masterPath string
+	stdout     io.Writer
+	flagset    *flag.FlagSet
+	templates  *bool
+	layoutsDir *string
+	dataDir    *string
+	includes   *bool
+}
+
+func Command() *command {
+	r, _ := os.Executable()
+	return &command{
+		binPath: r,
+	}
+}
+
+func (c *command) Setup() error {
+	relPath := c.flagset.Arg(0)
+	if relPath == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get exec path: %e", err)
+		}
+		relPath = wd
+	}
+	c.masterPath = path.Clean(relPath)
+
+	c.stdout = os.Stdout
+	if ancli.Stdout != nil {
+		c.stdout = ancli.Stdout
+	}
+	return nil
+}
+
+// Run checks the directory once, printing the broken references. It fails if any are found,
+// so that it can be used in CI.
+func (c *command) Run(context.Context) error {
+	var opts []linkcheck.Option
+	if *c.includes {
+		opts = append(opts, linkcheck.WithIncludes())
+	}
+	if *c.templates {
+		opts = append(opts, linkcheck.WithTemplates(c.rootRelative(*c.layoutsDir), c.rootRelative(*c.dataDir)))
+	}
+	issues, err := linkcheck.Check(c.masterPath, opts...)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		file := filepath.Join(c.masterPath, filepath.FromSlash(issue.Path))
+		issue.Emit(file)
+		fmt.Fprintf(c.stdout, "%v:%v: %v: '%v', %v\n", file, issue.Line, issue.Kind, issue.Ref, issue.Msg)
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("found %v broken references", len(issues))
+	}
+	ancli.OK("found no broken references", "path", c.masterPath)
+	return nil
+}
+
+// rootRelative resolves p relative to the checked directory, unless it is absolute.
+func (c *command) rootRelative(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.masterPath, p)
+}
+
+func (c *command) Help() string {
+	return "Check a directory for broken links and assets. Set the directory as the second argument: sws check <dir>. If omitted, current wd will be used."
+}
+
+func (c *command) Describe() string {
+	return fmt.Sprintf("check for broken links and assets. Usage: '%v check <path>'. If <path> is left unfilled, current pwd will be used.", c.binPath)
+}
+
+func (c *command) Flagset() *flag.FlagSet {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	c.templates = fs.Bool("templates", false, "set to true to resolve references to the '*.html' pages which '*.tmpl.html' files render to, as 'serve -templates' renders them")
+	c.layoutsDir = fs.String("layoutsDir", "_layouts", "directory with the layout templates, which is not checked, relative to the checked directory")
+	c.dataDir = fs.String("dataDir", "_data", "directory with the data files of templates, which is not checked, relative to the checked directory")
+	c.includes = fs.Bool("includes", true, "set to false to not resolve include directives when looking up the anchors of pages")
+	c.flagset = fs
+	return fs
+}
@@ -0,0 +1,75 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	setup := func(t *testing.T, page string, flags ...string) (*command, *bytes.Buffer) {
+		t.Helper()
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "index.html"), []byte(page), 0o644)
+		os.WriteFile(filepath.Join(root, "logo.png"), []byte("png"), 0o644)
+		os.WriteFile(filepath.Join(root, "about.tmpl.html"), []byte(`{{template "base.html" .}}`), 0o644)
+		cmd := Command()
+		if err := cmd.Flagset().Parse(append(flags, root)); err != nil {
+			t.Fatalf("failed to parse flags: %v", err)
+		}
+
+		if err := cmd.Setup(); err != nil {
+			t.Fatalf("failed to setup: %v", err)
+		}
+		var buf bytes.Buffer
+		cmd.stdout = &buf
+		return cmd, &buf
+	}
+
+	t.Run("it should succeed if all references resolve", func(t *testing.T) {
+		cmd, buf := setup(t, `<img src="logo.png">`)
+		if err := cmd.Run(context.Background()); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if buf.Len() != 0 {
+			t.Fatalf("expected no output, got: %v", buf.String())
+		}
+	})
+
+	t.Run("it should print broken references with file and line and fail", func(t *testing.T) {
+		cmd, buf := setup(t, "<img src=\"logo.png\">\n<a href=\"missing.html\">")
+		err := cmd.Run(context.Background())
+		if err == nil || err.Error() != "found 1 broken references" {
+			t.Fatalf("expected error for the broken reference, got: %v", err)
+		}
+
+		want := filepath.Join(cmd.masterPath, "index.html") + ":2: missing: 'missing.html', no file at: '/missing.html'\n"
+		if got := buf.String(); got != want {
+			t.Fatalf("expected: %q, got: %q", want, got)
+		}
+	})
+
+	t.Run("it should resolve the pages which templates render to with templates", func(t *testing.T) {
+		cmd, buf := setup(t, `<a href="about.html">about</a>`, "-templates")
+		if err := cmd.Run(context.Background()); err != nil {
+			t.Fatalf("expected no error, got: %v, output: %v", err, buf.String())
+		}
+	})
+
+	t.Run("it should not render templates by default, as serve does", func(t *testing.T) {
+		cmd, _ := setup(t, `<a href="about.html">about</a>`)
+		if err := cmd.Run(context.Background()); err == nil {
+			t.Fatal("expected the page of the template to be missing")
+		}
+	})
+
+	t.Run("it should describe the command", func(t *testing.T) {
+		if got := Command().Describe(); !strings.Contains(got, "check <path>") {
+			t.Fatalf("expected usage in description, got: %v", got)
+		}
+	})
+}
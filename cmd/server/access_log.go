@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pchchv/sws/helpers/ancli"
+)
+
+const (
+	accessLogPretty   = "pretty"
+	accessLogCommon   = "common"
+	accessLogCombined = "combined"
+	accessLogJSON     = "json"
+)
+
+// assetExtensions are the file extensions hidden by the asset filter.
+var assetExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".map": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".avif": true, ".ico": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp4": true, ".webm": true, ".mp3": true, ".wav": true, ".wasm": true,
+}
+
+// responseRecorder records the status code and amount of bytes written by a handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += n
+	return n, err
+}
+
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows websocket upgrades to pass through the recorder.
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying response writer does not support hijacking")
+	}
+	rr.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+type accessEntry struct {
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Bytes     int           `json:"bytes"`
+	Duration  time.Duration `json:"-"`
+	Remote    string        `json:"remote"`
+	Referer   string        `json:"referer,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty"`
+}
+
+// accessLogger formats finished requests and writes them to the terminal and,
+// optionally, to a log file.
+type accessLogger struct {
+	format     string
+	hideAssets bool
+	stdout     io.Writer
+	file       io.Writer
+	mu         sync.Mutex
+}
+
+func validAccessLogFormat(format string) bool {
+	switch format {
+	case accessLogPretty, accessLogCommon, accessLogCombined, accessLogJSON:
+		return true
+	}
+	return false
+}
+
+func isAsset(urlPath string) bool {
+	return assetExtensions[strings.ToLower(path.Ext(urlPath))]
+}
+
+func (al *accessLogger) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if al.hideAssets && rec.status < 400 && isAsset(r.URL.Path) {
+			return
+		}
+
+		remote, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remote = r.RemoteAddr
+		}
+
+		al.log(accessEntry{
+			Time:      start,
+			Method:    r.Method,
+			Path:      redactedURI(r.URL),
+			Proto:     r.Proto,
+			Status:    rec.status,
+			Bytes:     rec.bytes,
+			Duration:  time.Since(start),
+			Remote:    remote,
+			Referer:   redactedReferer(r.Referer()),
+			UserAgent: r.UserAgent(),
+		})
+	})
+}
+
+func (al *accessLogger) log(e accessEntry) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	line := formatAccessEntry(al.format, e)
+	if al.format == accessLogPretty {
+		printPretty(e)
+	} else {
+		fmt.Fprintln(al.stdout, line)
+	}
+
+	if al.file != nil {
+		if _, err := fmt.Fprintln(al.file, line); err != nil {
+			ancli.PrintfErr("failed to write access log: %v", err)
+		}
+	}
+}
+
+// printPretty prints the entry as a structured log line,
+// highlighting client errors as warnings and server errors as errors.
+func printPretty(e accessEntry) {
+	msg := e.Method + " " + e.Path
+	attrs := []any{
+		"status", e.Status,
+		"bytes", e.Bytes,
+		"duration", e.Duration,
+		"client", e.Remote,
+		"user_agent", e.UserAgent,
+	}
+	switch {
+	case e.Status >= 500:
+		ancli.Err(msg, attrs...)
+	case e.Status >= 400:
+		ancli.Warn(msg, attrs...)
+	default:
+		ancli.OK(msg, attrs...)
+	}
+}
+
+func formatPretty(e accessEntry) string {
+	return fmt.Sprintf("%s %s %v - %v bytes in %v - %s %q",
+		e.Method, e.Path, e.Status, e.Bytes, e.Duration.Round(time.Microsecond), e.Remote, e.UserAgent)
+}
+
+func formatAccessEntry(format string, e accessEntry) string {
+	switch format {
+	case accessLogCommon:
+		return formatCommon(e)
+	case accessLogCombined:
+		return fmt.Sprintf("%s %q %q", formatCommon(e), dashIfEmpty(e.Referer), dashIfEmpty(e.UserAgent))
+	case accessLogJSON:
+		b, err := json.Marshal(struct {
+			accessEntry
+			DurationMs float64 `json:"duration_ms"`
+		}{e, float64(e.Duration.Microseconds()) / 1000})
+		if err != nil {
+			return fmt.Sprintf(`{"error":%q}`, err.Error())
+		}
+		return string(b)
+	default:
+		return formatPretty(e)
+	}
+}
+
+// formatCommon formats the entry according to the Common Log Format.
+func formatCommon(e accessEntry) string {
+	size := "-"
+	if e.Bytes > 0 {
+		size = fmt.Sprint(e.Bytes)
+	}
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s",
+		e.Remote, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method, e.Path, e.Proto, e.Status, size)
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// redactedURI returns the request uri of u, with the access token redacted.
+func redactedURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	redacted := *u
+	redacted.RawQuery = redactParam(u.RawQuery, tokenParam)
+	return redacted.RequestURI()
+}
+
+// redactedReferer returns the referer, with the access token redacted.
+func redactedReferer(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil || u.RawQuery == "" {
+		return referer
+	}
+	u.RawQuery = redactParam(u.RawQuery, tokenParam)
+	return u.String()
+}
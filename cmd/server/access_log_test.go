@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_accessLogger(t *testing.T) {
+	setup := func(t *testing.T, format string, hideAssets bool, status int) (*bytes.Buffer, *bytes.Buffer, http.Handler) {
+		t.Helper()
+		var stdout, file bytes.Buffer
+		al := &accessLogger{
+			format:     format,
+			hideAssets: hideAssets,
+			stdout:     &stdout,
+			file:       &file,
+		}
+		h := al.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte("hello"))
+		}))
+		return &stdout, &file, h
+	}
+
+	doRequest := func(h http.Handler, target string) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.RemoteAddr = "10.0.0.2:51234"
+		r.Header.Set("User-Agent", "test-agent")
+		r.Header.Set("Referer", "http://localhost/")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	t.Run("it should log status and size in common log format", func(t *testing.T) {
+		stdout, file, h := setup(t, accessLogCommon, false, http.StatusNotFound)
+		doRequest(h, "/missing.html")
+		got := stdout.String()
+		want := `"GET /missing.html HTTP/1.1" 404 5`
+		if !strings.HasPrefix(got, "10.0.0.2 - - [") || !strings.Contains(got, want) {
+			t.Fatalf("expected: '%v' in common log line, got: '%v'", want, got)
+		}
+
+		if file.String() != got {
+			t.Fatalf("expected log file to contain: '%v', got: '%v'", got, file.String())
+		}
+	})
+
+	t.Run("it should append referer and user agent in combined log format", func(t *testing.T) {
+		stdout, _, h := setup(t, accessLogCombined, false, http.StatusOK)
+		doRequest(h, "/")
+		if want := `"http://localhost/" "test-agent"`; !strings.Contains(stdout.String(), want) {
+			t.Fatalf("expected: '%v' in combined log line, got: '%v'", want, stdout.String())
+		}
+	})
+
+	t.Run("it should write json lines", func(t *testing.T) {
+		stdout, _, h := setup(t, accessLogJSON, false, http.StatusInternalServerError)
+		doRequest(h, "/index.html")
+		var got map[string]any
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal json line: %v", err)
+		}
+
+		if got["status"] != float64(500) || got["path"] != "/index.html" || got["bytes"] != float64(5) {
+			t.Fatalf("unexpected json entry: %v", got)
+		}
+
+		if _, ok := got["duration_ms"]; !ok {
+			t.Fatalf("expected json entry to contain duration_ms, got: %v", got)
+		}
+	})
+
+	t.Run("it should hide successful asset requests", func(t *testing.T) {
+		stdout, file, h := setup(t, accessLogCommon, true, http.StatusOK)
+		doRequest(h, "/css/style.css")
+		if stdout.Len() != 0 || file.Len() != 0 {
+			t.Fatalf("expected asset request to be hidden, got: '%v'", stdout.String())
+		}
+
+		doRequest(h, "/index.html")
+		if stdout.Len() == 0 {
+			t.Fatal("expected html request to be logged")
+		}
+	})
+
+	t.Run("it should not hide failed asset requests", func(t *testing.T) {
+		stdout, _, h := setup(t, accessLogCommon, true, http.StatusNotFound)
+		doRequest(h, "/img/missing.png")
+		if stdout.Len() == 0 {
+			t.Fatal("expected failed asset request to be logged")
+		}
+	})
+
+	t.Run("it should redact the access token", func(t *testing.T) {
+		stdout, file, h := setup(t, accessLogCommon, false, http.StatusOK)
+		doRequest(h, "/?a=1&sws_token=secret")
+		if want := `"GET /?a=1&sws_token=redacted HTTP/1.1"`; !strings.Contains(stdout.String(), want) {
+			t.Fatalf("expected: '%v' in log line, got: '%v'", want, stdout.String())
+		}
+
+		if strings.Contains(stdout.String()+file.String(), "secret") {
+			t.Fatalf("expected the token to be redacted, got: '%v'", stdout.String())
+		}
+	})
+}
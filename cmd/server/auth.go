@@ -0,0 +1,193 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// tokenParam is the query parameter and cookie name carrying the access token.
+const tokenParam = "sws_token"
+
+// auth protects the server with either http basic authentication or an access token.
+// The websocket accepts the wsToken as well, which the delta-streamer script passes
+// along when it connects, since browsers do not reliably send basic auth credentials
+// on websocket handshakes.
+type auth struct {
+	user     string
+	password string
+	token    string
+	wsToken  string
+}
+
+// newAuth returns nil if neither basic auth credentials as 'user:pass' nor a token is set.
+func newAuth(credentials, token string) (*auth, error) {
+	if credentials == "" && token == "" {
+		return nil, nil
+	}
+
+	if credentials != "" && token != "" {
+		return nil, errors.New("only one of basic auth and token can be set")
+	}
+
+	a := &auth{token: token, wsToken: token}
+	if credentials != "" {
+		user, password, found := strings.Cut(credentials, ":")
+		if !found || user == "" || password == "" {
+			return nil, errors.New("basic auth credentials have to be formatted as 'user:pass'")
+		}
+		a.user, a.password = user, password
+
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		a.wsToken = hex.EncodeToString(b)
+	}
+	return a, nil
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// requestToken returns the token of the request, from either the query, cookie
+// or a bearer authorization header.
+func requestToken(r *http.Request) (string, bool) {
+	if t := r.URL.Query().Get(tokenParam); t != "" {
+		return t, true
+	}
+
+	if c, err := r.Cookie(tokenParam); err == nil && c.Value != "" {
+		return c.Value, false
+	}
+
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return t, false
+	}
+	return "", false
+}
+
+// authorized reports if r is authorized, and if it was by a token in the query. With basic auth,
+// the websocket token is part of the client script any visitor can fetch, so it only authorizes
+// websocket handshakes.
+func (a *auth) authorized(r *http.Request, websocket bool) (bool, bool) {
+	token, fromQuery := requestToken(r)
+	if token != "" && (a.user == "" || websocket) && secureEqual(token, a.wsToken) {
+		return true, fromQuery
+	}
+
+	if a.user != "" {
+		user, password, ok := r.BasicAuth()
+		// both are compared to not leak which one is wrong through timing
+		userOK := secureEqual(user, a.user)
+		passwordOK := secureEqual(password, a.password)
+		return ok && userOK && passwordOK, false
+	}
+	return false, false
+}
+
+// handler protects the pages and files of next.
+func (a *auth) handler(next http.Handler) http.Handler {
+	return a.protect(next, false)
+}
+
+// wsHandler protects the websocket of next, which also accepts the websocket token.
+func (a *auth) wsHandler(next http.Handler) http.Handler {
+	return a.protect(next, true)
+}
+
+func (a *auth) protect(next http.Handler, websocket bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, fromQuery := a.authorized(r, websocket)
+		if !ok {
+			if a.user != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="sws", charset="UTF-8"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "unauthorized, open the page with '?"+tokenParam+"=<token>' once to sign in", http.StatusUnauthorized)
+			return
+		}
+
+		// remember the token for the subresources and later page loads
+		if fromQuery && a.token != "" {
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenParam,
+				Value:    a.token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+
+			// page loads are redirected to drop the token from the address bar, websocket
+			// handshakes can not follow redirects and pass the token on every connection
+			if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				u := *r.URL
+				u.RawQuery = withoutParam(u.RawQuery, tokenParam)
+				http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withoutParam returns the raw query without the parameter name, keeping the order of the others.
+func withoutParam(rawQuery, name string) string {
+	return mapParam(rawQuery, name, func(string) (string, bool) { return "", false })
+}
+
+// redactParam returns the raw query with the value of the parameter name redacted.
+func redactParam(rawQuery, name string) string {
+	return mapParam(rawQuery, name, func(key string) (string, bool) { return key + "=redacted", true })
+}
+
+// mapParam replaces the pairs of the parameter name in rawQuery with the result of replace,
+// or drops them if it returns false. Other pairs are kept as they are.
+func mapParam(rawQuery, name string, replace func(key string) (string, bool)) string {
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(key); err != nil || unescaped != name {
+			kept = append(kept, pair)
+		} else if replaced, ok := replace(key); ok {
+			kept = append(kept, replaced)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// checkOrigin returns the websocket origin check for allowedOrigins.
+// Same origin requests are always allowed, '*' allows all origins.
+func checkOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin) {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// parseOrigins splits a comma separated list of origins.
+func parseOrigins(s string) []string {
+	var origins []string
+	for _, o := range strings.Split(s, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
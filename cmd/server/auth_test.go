@@ -0,0 +1,146 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_newAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials string
+		token       string
+		wantNil     bool
+		wantErr     bool
+	}{
+		{name: "no auth", wantNil: true},
+		{name: "basic auth", credentials: "user:pass"},
+		{name: "token", token: "secret"},
+		{name: "both", credentials: "user:pass", token: "secret", wantErr: true},
+		{name: "missing password", credentials: "user", wantErr: true},
+		{name: "empty password", credentials: "user:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newAuth(tt.credentials, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+
+			if !tt.wantErr && (got == nil) != tt.wantNil {
+				t.Fatalf("expected nil: %v, got: %+v", tt.wantNil, got)
+			}
+		})
+	}
+}
+
+func Test_auth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	do := func(h http.Handler, r *http.Request) *http.Response {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Result()
+	}
+
+	t.Run("it should require basic auth credentials", func(t *testing.T) {
+		a, _ := newAuth("user:pass", "")
+		h := a.handler(ok)
+
+		resp := do(h, httptest.NewRequest(http.MethodGet, "/", nil))
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Fatalf("expected basic auth challenge, got: %v", resp.Status)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetBasicAuth("user", "wrong")
+		if resp := do(h, r); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected wrong password to be rejected, got: %v", resp.Status)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetBasicAuth("user", "pass")
+		if resp := do(h, r); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected valid credentials to pass, got: %v", resp.Status)
+		}
+
+		if resp := do(a.wsHandler(ok), httptest.NewRequest(http.MethodGet, "/ws?sws_token="+a.wsToken, nil)); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected websocket token to pass the websocket, got: %v", resp.Status)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+a.wsToken)
+		if resp := do(h, r); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected websocket token to be rejected outside of the websocket, got: %v", resp.Status)
+		}
+	})
+
+	t.Run("it should accept the token from query, cookie or bearer header", func(t *testing.T) {
+		a, _ := newAuth("", "secret")
+		h := a.handler(ok)
+
+		if resp := do(h, httptest.NewRequest(http.MethodGet, "/?sws_token=wrong", nil)); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected wrong token to be rejected, got: %v", resp.Status)
+		}
+
+		resp := do(h, httptest.NewRequest(http.MethodGet, "/blog/?page=2&sws_token=secret&sort=new", nil))
+		if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/blog/?page=2&sort=new" {
+			t.Fatalf("expected token in query to pass and redirect without it, got: %v %v", resp.Status, resp.Header.Get("Location"))
+		}
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Name != tokenParam || !cookies[0].HttpOnly {
+			t.Fatalf("expected http only token cookie to be set, got: %v", cookies)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		r.AddCookie(cookies[0])
+		if resp := do(h, r); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected token cookie to pass, got: %v", resp.Status)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		if resp := do(h, r); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected bearer token to pass, got: %v", resp.Status)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/ws?sws_token=secret", nil)
+		r.Header.Set("Upgrade", "websocket")
+		if resp := do(h, r); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected websocket handshake with token in query to pass without redirect, got: %v", resp.Status)
+		}
+	})
+}
+
+func Test_checkOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed string
+		origin  string
+		want    bool
+	}{
+		{name: "no origin", origin: "", want: true},
+		{name: "same origin", origin: "http://192.168.1.2:8080", want: true},
+		{name: "other origin", origin: "http://evil.example", want: false},
+		{name: "allowed origin", allowed: "http://a.example, http://evil.example/", origin: "http://evil.example", want: true},
+		{name: "wildcard", allowed: "*", origin: "http://evil.example", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://192.168.1.2:8080/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			if got := checkOrigin(parseOrigins(tt.allowed))(r); got != tt.want {
+				t.Fatalf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+	// responses smaller than this are not worth compressing
+	minCompressSize = 1024
+)
+
+// precompressedExtensions maps encodings to the extension of their precompressed siblings.
+var precompressedExtensions = map[string]string{
+	encodingBrotli: ".br",
+	encodingGzip:   ".gz",
+}
+
+// compressibleTypes are the non text/* mime types which are worth compressing.
+var compressibleTypes = map[string]bool{
+	"application/javascript":    true,
+	"application/json":          true,
+	"application/manifest+json": true,
+	"application/wasm":          true,
+	"application/xml":           true,
+	"application/xhtml+xml":     true,
+	"application/rss+xml":       true,
+	"application/atom+xml":      true,
+	"image/svg+xml":             true,
+	"image/x-icon":              true,
+	"font/ttf":                  true,
+	"font/otf":                  true,
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || compressibleTypes[mediaType]
+}
+
+// acceptedEncodings returns brotli and gzip in order of preference, as given
+// by the q-values of the Accept-Encoding header, leaving out those which are not acceptable.
+func acceptedEncodings(acceptEncoding string) []string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		switch coding {
+		case encodingBrotli, encodingGzip:
+			weights[coding] = q
+		case "*":
+			for _, enc := range []string{encodingBrotli, encodingGzip} {
+				if _, set := weights[enc]; !set {
+					weights[enc] = q
+				}
+			}
+		}
+	}
+
+	var encodings []string
+	// brotli is preferred on equal weights
+	for _, enc := range []string{encodingBrotli, encodingGzip} {
+		if weights[enc] > 0 {
+			encodings = append(encodings, enc)
+		}
+	}
+	if len(encodings) == 2 && weights[encodingGzip] > weights[encodingBrotli] {
+		encodings[0], encodings[1] = encodings[1], encodings[0]
+	}
+	return encodings
+}
+
+// compressionHandler compresses responses of compressible mime types with the
+// encoding negotiated from the Accept-Encoding header.
+// If a precompressed '.br' or '.gz' sibling of a requested file exists in root,
+// it is served directly instead.
+func compressionHandler(next http.Handler, root string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encodings := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+		if len(encodings) == 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if servePrecompressed(w, r, root, encodings) {
+			return
+		}
+
+		// compressing partial content would break the byte ranges
+		if r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encodings[0]}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// servePrecompressed serves the precompressed sibling of the requested file, if it exists.
+// Html files are skipped, as their siblings lack the injected delta-streamer script.
+func servePrecompressed(w http.ResponseWriter, r *http.Request, root string, encodings []string) bool {
+	urlPath := path.Clean("/" + r.URL.Path)
+	ext := strings.ToLower(path.Ext(urlPath))
+	if strings.HasSuffix(r.URL.Path, "/") || ext == "" || ext == ".html" || ext == ".htm" {
+		return false
+	}
+
+	for _, enc := range encodings {
+		f, err := os.Open(filepath.Join(root, filepath.FromSlash(urlPath)+precompressedExtensions[enc]))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", enc)
+		http.ServeContent(w, r, urlPath, info.ModTime(), f)
+		return true
+	}
+	return false
+}
+
+// compressWriter decides on the first write whether to compress the response,
+// based on the status, content type and content length set by the wrapped handler.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	decided     bool
+	compressor  io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressWriter) decide(status int) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+
+	h := cw.Header()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+
+	if cl, err := strconv.Atoi(h.Get("Content-Length")); err == nil && cl < minCompressSize {
+		return
+	}
+
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	h.Set("Content-Encoding", cw.encoding)
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+
+	switch cw.encoding {
+	case encodingBrotli:
+		cw.compressor = brotli.NewWriterLevel(cw.ResponseWriter, brotli.DefaultCompression)
+	case encodingGzip:
+		cw.compressor = gzip.NewWriter(cw.ResponseWriter)
+	}
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.decide(status)
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.compressor != nil {
+		return cw.compressor.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressWriter) Close() error {
+	if cw.compressor != nil {
+		return cw.compressor.Close()
+	}
+	return nil
+}
+
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.compressor.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows websocket upgrades to pass through the writer.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
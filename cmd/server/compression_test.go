@@ -0,0 +1,139 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func Test_acceptedEncodings(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{header: "", want: nil},
+		{header: "gzip", want: []string{"gzip"}},
+		{header: "gzip, deflate, br", want: []string{"br", "gzip"}},
+		{header: "br;q=0.5, gzip;q=0.8", want: []string{"gzip", "br"}},
+		{header: "br;q=0, gzip", want: []string{"gzip"}},
+		{header: "*", want: []string{"br", "gzip"}},
+		{header: "identity", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := acceptedEncodings(tt.header); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_compressionHandler(t *testing.T) {
+	largeJS := strings.Repeat("console.log('hello');\n", 200)
+	setup := func(t *testing.T) (string, http.Handler) {
+		t.Helper()
+		root := t.TempDir()
+		os.WriteFile(path.Join(root, "app.js"), []byte(largeJS), 0o644)
+		os.WriteFile(path.Join(root, "small.css"), []byte("body{}"), 0o644)
+		os.WriteFile(path.Join(root, "image.png"), []byte(strings.Repeat("\x89PNG", 500)), 0o644)
+		return root, compressionHandler(http.FileServer(http.Dir(root)), root)
+	}
+
+	get := func(h http.Handler, target, acceptEncoding string) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Result()
+	}
+
+	t.Run("it should gzip compressible responses", func(t *testing.T) {
+		_, h := setup(t)
+		resp := get(h, "/app.js", "gzip")
+		if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("expected gzip content encoding, got: '%v'", got)
+		}
+
+		if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+			t.Fatalf("expected Vary: Accept-Encoding, got: '%v'", got)
+		}
+
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to create gzip reader: %v", err)
+		}
+		if b, _ := io.ReadAll(gr); string(b) != largeJS {
+			t.Fatal("expected decompressed body to equal original file")
+		}
+	})
+
+	t.Run("it should prefer brotli", func(t *testing.T) {
+		_, h := setup(t)
+		resp := get(h, "/app.js", "gzip, br")
+		if got := resp.Header.Get("Content-Encoding"); got != "br" {
+			t.Fatalf("expected br content encoding, got: '%v'", got)
+		}
+
+		if b, _ := io.ReadAll(brotli.NewReader(resp.Body)); string(b) != largeJS {
+			t.Fatal("expected decompressed body to equal original file")
+		}
+	})
+
+	t.Run("it should not compress small or incompressible responses", func(t *testing.T) {
+		_, h := setup(t)
+		for _, target := range []string{"/small.css", "/image.png"} {
+			if got := get(h, target, "gzip").Header.Get("Content-Encoding"); got != "" {
+				t.Fatalf("expected no content encoding for: '%v', got: '%v'", target, got)
+			}
+		}
+	})
+
+	t.Run("it should not compress without accepted encoding", func(t *testing.T) {
+		_, h := setup(t)
+		resp := get(h, "/app.js", "")
+		if got := resp.Header.Get("Content-Encoding"); got != "" {
+			t.Fatalf("expected no content encoding, got: '%v'", got)
+		}
+
+		if b, _ := io.ReadAll(resp.Body); string(b) != largeJS {
+			t.Fatal("expected body to equal original file")
+		}
+	})
+
+	t.Run("it should serve precompressed siblings", func(t *testing.T) {
+		root, h := setup(t)
+		want := "precompressed brotli bytes"
+		os.WriteFile(path.Join(root, "app.js.br"), []byte(want), 0o644)
+		resp := get(h, "/app.js", "gzip, br")
+		if got := resp.Header.Get("Content-Encoding"); got != "br" {
+			t.Fatalf("expected br content encoding, got: '%v'", got)
+		}
+
+		if got := resp.Header.Get("Content-Type"); !strings.Contains(got, "javascript") {
+			t.Fatalf("expected javascript content type, got: '%v'", got)
+		}
+
+		if b, _ := io.ReadAll(resp.Body); string(b) != want {
+			t.Fatalf("expected: '%v', got: '%v'", want, string(b))
+		}
+	})
+
+	t.Run("it should fall back to gzip sibling if brotli sibling is missing", func(t *testing.T) {
+		root, h := setup(t)
+		os.WriteFile(path.Join(root, "app.js.gz"), []byte("precompressed gzip bytes"), 0o644)
+		if got := get(h, "/app.js", "gzip, br").Header.Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("expected gzip content encoding, got: '%v'", got)
+		}
+	})
+}
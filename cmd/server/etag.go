@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// etagger sets strong ETags, from the content hash of the served files in root,
+// so that conditional requests behave as on hosts which do the same.
+// Hashes are cached until the modification time or size of a file changes.
+type etagger struct {
+	root  string
+	mu    sync.Mutex
+	cache map[string]etagEntry
+}
+
+func newEtagger(root string) *etagger {
+	return &etagger{
+		root:  root,
+		cache: map[string]etagEntry{},
+	}
+}
+
+func (e *etagger) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if etag, ok := e.etag(r.URL.Path); ok {
+				// http.FileServer uses the ETag for If-None-Match and If-Range requests
+				w.Header().Set("ETag", etag)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// etag returns the ETag of the file served on urlPath, directories are served by their index.html.
+func (e *etagger) etag(urlPath string) (string, bool) {
+	p := filepath.Join(e.root, filepath.FromSlash(path.Clean("/"+urlPath)))
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", false
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(urlPath, "/") {
+			// redirected to the path with a trailing slash
+			return "", false
+		}
+		p = filepath.Join(p, "index.html")
+		if info, err = os.Stat(p); err != nil || info.IsDir() {
+			return "", false
+		}
+	}
+
+	e.mu.Lock()
+	cached, ok := e.cache[p]
+	e.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.etag, true
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", false
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+
+	e.mu.Lock()
+	e.cache[p] = etagEntry{modTime: info.ModTime(), size: info.Size(), etag: etag}
+	e.mu.Unlock()
+	return etag, true
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_etagger(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "index.html"), []byte("<html></html>"), 0o644)
+	os.WriteFile(filepath.Join(root, "app.js"), []byte("console.log('v1')"), 0o644)
+	h := newEtagger(root).handler(http.FileServer(http.Dir(root)))
+
+	get := func(target, ifNoneMatch string) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Result()
+	}
+
+	t.Run("it should set a strong etag", func(t *testing.T) {
+		etag := get("/app.js", "").Header.Get("ETag")
+		if len(etag) < 3 || etag[0] != '"' {
+			t.Fatalf("expected strong etag, got: '%v'", etag)
+		}
+	})
+
+	t.Run("it should respond not modified for matching etags", func(t *testing.T) {
+		etag := get("/app.js", "").Header.Get("ETag")
+		if got := get("/app.js", etag).StatusCode; got != http.StatusNotModified {
+			t.Fatalf("expected status: %v, got: %v", http.StatusNotModified, got)
+		}
+
+		if got := get("/app.js", "W/"+etag).StatusCode; got != http.StatusNotModified {
+			t.Fatalf("expected weak etag to match, got: %v", got)
+		}
+	})
+
+	t.Run("it should change the etag when the content changes", func(t *testing.T) {
+		before := get("/app.js", "").Header.Get("ETag")
+		p := filepath.Join(root, "app.js")
+		os.WriteFile(p, []byte("console.log('v2')"), 0o644)
+		later := time.Now().Add(time.Second)
+		os.Chtimes(p, later, later)
+		if after := get("/app.js", "").Header.Get("ETag"); after == before {
+			t.Fatalf("expected etag to change, got: '%v'", after)
+		}
+	})
+
+	t.Run("it should set the etag of directory indexes", func(t *testing.T) {
+		if etag := get("/", "").Header.Get("ETag"); etag == "" {
+			t.Fatal("expected etag for directory index")
+		}
+	})
+
+	t.Run("it should not set etags for missing files", func(t *testing.T) {
+		if etag := get("/missing.js", "").Header.Get("ETag"); etag != "" {
+			t.Fatalf("expected no etag, got: '%v'", etag)
+		}
+	})
+}
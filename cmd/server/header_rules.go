@@ -0,0 +1,316 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const headersFileName = "_headers"
+
+type headerOp int
+
+const (
+	headerSet headerOp = iota
+	headerAppend
+	headerRemove
+)
+
+type headerAction struct {
+	op    headerOp
+	name  string
+	value string
+}
+
+type headerRule struct {
+	pattern *urlPattern
+	actions []headerAction
+}
+
+// parseHeaderAction parses a header line, which is one of:
+//
+//	Name: value   sets the header
+//	+Name: value  appends a value to the header
+//	! Name        removes the header
+func parseHeaderAction(line string) (headerAction, error) {
+	if name, ok := strings.CutPrefix(line, "!"); ok {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return headerAction{}, errors.New("missing header name to remove")
+		}
+		return headerAction{op: headerRemove, name: http.CanonicalHeaderKey(name)}, nil
+	}
+
+	op := headerSet
+	if rest, ok := strings.CutPrefix(line, "+"); ok {
+		op = headerAppend
+		line = rest
+	}
+
+	name, value, found := strings.Cut(line, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return headerAction{}, fmt.Errorf("invalid header line: '%v', expected 'Name: value'", line)
+	}
+	return headerAction{op: op, name: http.CanonicalHeaderKey(name), value: strings.TrimSpace(value)}, nil
+}
+
+// parseHeaderRules parses a netlify style _headers file, where unindented
+// lines are url patterns followed by indented header lines, see parseHeaderAction.
+// Repeated set lines for the same header within a rule are combined into multiple values.
+func parseHeaderRules(b []byte) ([]headerRule, error) {
+	var rules []headerRule
+	var current *headerRule
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	lineNr := 0
+	for scanner.Scan() {
+		lineNr++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if raw[0] != ' ' && raw[0] != '\t' {
+			pattern, err := compileURLPattern(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", lineNr, err)
+			}
+			rules = append(rules, headerRule{pattern: pattern})
+			current = &rules[len(rules)-1]
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %v: header without preceding url pattern", lineNr)
+		}
+
+		action, err := parseHeaderAction(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", lineNr, err)
+		}
+
+		if action.op == headerSet {
+			for _, prev := range current.actions {
+				if prev.op == headerSet && prev.name == action.name {
+					action.op = headerAppend
+					break
+				}
+			}
+		}
+		current.actions = append(current.actions, action)
+	}
+	return rules, scanner.Err()
+}
+
+// parseHeaderEntry parses a header rule given as a single config entry: '<pattern> <header line>'.
+func parseHeaderEntry(entry string) (headerRule, error) {
+	rawPattern, line, found := strings.Cut(strings.TrimSpace(entry), " ")
+	if !found {
+		return headerRule{}, fmt.Errorf("invalid header entry: '%v', expected '<pattern> <Name>: <value>'", entry)
+	}
+
+	pattern, err := compileURLPattern(rawPattern)
+	if err != nil {
+		return headerRule{}, err
+	}
+
+	action, err := parseHeaderAction(strings.TrimSpace(line))
+	if err != nil {
+		return headerRule{}, err
+	}
+	return headerRule{pattern: pattern, actions: []headerAction{action}}, nil
+}
+
+// headerRules applies the rules of the headers file followed by the
+// rules given as config entries.
+type headerRules struct {
+	file    *reloadingFile[[]headerRule]
+	entries []headerRule
+	// wsPort is the port of the websocket, whose origin is added to content security policies
+	wsPort int
+}
+
+func newHeaderRules(filePath string, entries []string, wsPort int) (*headerRules, error) {
+	hr := &headerRules{
+		file: &reloadingFile[[]headerRule]{
+			path:  filePath,
+			parse: parseHeaderRules,
+		},
+		wsPort: wsPort,
+	}
+	for _, entry := range entries {
+		rule, err := parseHeaderEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		hr.entries = append(hr.entries, rule)
+	}
+	return hr, nil
+}
+
+func (hr *headerRules) apply(h http.Header, r *http.Request) {
+	urlPath := r.URL.Path
+	for _, rules := range [][]headerRule{hr.file.get(), hr.entries} {
+		for _, rule := range rules {
+			if _, ok := rule.pattern.match(urlPath); !ok {
+				continue
+			}
+
+			for _, action := range rule.actions {
+				switch action.op {
+				case headerSet:
+					h.Set(action.name, action.value)
+				case headerAppend:
+					h.Add(action.name, action.value)
+				case headerRemove:
+					h.Del(action.name)
+				}
+			}
+		}
+	}
+
+	for _, name := range []string{"Content-Security-Policy", "Content-Security-Policy-Report-Only"} {
+		if policies := h.Values(name); len(policies) > 0 {
+			adjusted := make([]string, len(policies))
+			for i, policy := range policies {
+				adjusted[i] = adjustCSP(policy, hr.wsSource(r))
+			}
+			h[name] = adjusted
+		}
+	}
+}
+
+func (hr *headerRules) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&headerHookWriter{
+			ResponseWriter: w,
+			hook: func(h http.Header) {
+				hr.apply(h, r)
+			},
+		}, r)
+	})
+}
+
+// wsSource returns the websocket origin the client of a page requested with r connects to.
+// It is on the host the page was requested from, which may be a LAN address rather than localhost.
+func (hr *headerRules) wsSource(r *http.Request) string {
+	scheme := "ws"
+	if r.TLS != nil {
+		scheme = "wss"
+	}
+
+	host := r.Host
+	if hr.wsPort != 0 {
+		hostname := host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			hostname = h
+		}
+		host = net.JoinHostPort(strings.Trim(hostname, "[]"), strconv.Itoa(hr.wsPort))
+	}
+	return scheme + "://" + host
+}
+
+// adjustCSP makes sure the content security policy allows the injected delta-streamer
+// script, served from the same origin, and its websocket connection to wsSource.
+func adjustCSP(policy, wsSource string) string {
+	directives := map[string][]string{}
+	var order []string
+	for _, d := range strings.Split(policy, ";") {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := strings.ToLower(fields[0])
+		if _, exists := directives[name]; exists {
+			// the first occurrence of a directive wins, as in browsers
+			continue
+		}
+		directives[name] = fields[1:]
+		order = append(order, name)
+	}
+
+	allow := func(directive string, sources ...string) {
+		values, exists := directives[directive]
+		if !exists {
+			defaults, hasDefault := directives["default-src"]
+			if !hasDefault {
+				// not restricted by the policy
+				return
+			}
+			values = append([]string{}, defaults...)
+			order = append(order, directive)
+		}
+
+		values = slices.DeleteFunc(values, func(v string) bool {
+			return strings.EqualFold(v, "'none'")
+		})
+		for _, source := range sources {
+			if !slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, source) }) {
+				values = append(values, source)
+			}
+		}
+		directives[directive] = values
+	}
+
+	allow("script-src", "'self'")
+	if _, exists := directives["script-src-elem"]; exists {
+		allow("script-src-elem", "'self'")
+	}
+	allow("connect-src", "'self'", wsSource)
+
+	parts := make([]string, 0, len(order))
+	for _, name := range order {
+		parts = append(parts, strings.TrimSpace(name+" "+strings.Join(directives[name], " ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// headerHookWriter calls hook with the response headers right before they are written.
+type headerHookWriter struct {
+	http.ResponseWriter
+	hook        func(http.Header)
+	wroteHeader bool
+}
+
+func (hw *headerHookWriter) WriteHeader(status int) {
+	if !hw.wroteHeader {
+		hw.wroteHeader = true
+		hw.hook(hw.Header())
+	}
+	hw.ResponseWriter.WriteHeader(status)
+}
+
+func (hw *headerHookWriter) Write(b []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(b)
+}
+
+func (hw *headerHookWriter) Flush() {
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows websocket upgrades to pass through the writer.
+func (hw *headerHookWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := hw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (hw *headerHookWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"slices"
+	"testing"
+	"time"
+)
+
+const testHeadersFile = `# comment
+/*
+  X-Frame-Options: DENY
+  X-Robots-Tag: noindex
+
+/fonts/*
+  Access-Control-Allow-Origin: *
+
+/assets/*.js
+  Cache-Control: public, max-age=31536000, immutable
+
+/*.html
+  Link: </style.css>; rel=preload
+  Link: </app.js>; rel=preload
+  ! X-Robots-Tag
+`
+
+func Test_parseHeaderRules(t *testing.T) {
+	t.Run("it should parse patterns and header lines", func(t *testing.T) {
+		rules, err := parseHeaderRules([]byte(testHeadersFile))
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+
+		if len(rules) != 4 {
+			t.Fatalf("expected 4 rules, got: %v", len(rules))
+		}
+
+		html := rules[3]
+		if len(html.actions) != 3 || html.actions[1].op != headerAppend || html.actions[2].op != headerRemove {
+			t.Fatalf("expected repeated header to append and '!' to remove, got: %+v", html.actions)
+		}
+	})
+
+	t.Run("it should return error on header without pattern", func(t *testing.T) {
+		if _, err := parseHeaderRules([]byte("  X-Test: a\n")); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func Test_headerRules(t *testing.T) {
+	setup := func(t *testing.T, entries ...string) (string, http.Handler) {
+		t.Helper()
+		root := t.TempDir()
+		headersFile := path.Join(root, headersFileName)
+		os.WriteFile(headersFile, []byte(testHeadersFile), 0o644)
+		hr, err := newHeaderRules(headersFile, entries, 8080)
+		if err != nil {
+			t.Fatalf("failed to create header rules: %v", err)
+		}
+
+		h := cacheHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Robots-Tag", "all")
+			w.Write([]byte("ok"))
+		}), "no-cache")
+		return headersFile, hr.handler(h)
+	}
+
+	get := func(h http.Handler, target string) http.Header {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Result().Header
+	}
+
+	t.Run("it should set headers on matching paths", func(t *testing.T) {
+		_, h := setup(t)
+		got := get(h, "/fonts/inter.woff2")
+		if got.Get("Access-Control-Allow-Origin") != "*" || got.Get("X-Frame-Options") != "DENY" {
+			t.Fatalf("unexpected headers: %v", got)
+		}
+
+		if got.Get("X-Robots-Tag") != "noindex" {
+			t.Fatalf("expected rule to override handler header, got: %v", got.Get("X-Robots-Tag"))
+		}
+	})
+
+	t.Run("it should override headers set by other handlers", func(t *testing.T) {
+		_, h := setup(t)
+		if got := get(h, "/assets/app.3f2a.js").Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+			t.Fatalf("unexpected cache control: %v", got)
+		}
+	})
+
+	t.Run("it should append and remove headers", func(t *testing.T) {
+		_, h := setup(t)
+		got := get(h, "/index.html")
+		if want := []string{"</style.css>; rel=preload", "</app.js>; rel=preload"}; !slices.Equal(got.Values("Link"), want) {
+			t.Fatalf("expected: %v, got: %v", want, got.Values("Link"))
+		}
+
+		if _, ok := got["X-Robots-Tag"]; ok {
+			t.Fatalf("expected X-Robots-Tag to be removed, got: %v", got.Values("X-Robots-Tag"))
+		}
+	})
+
+	t.Run("it should apply config entries after the file", func(t *testing.T) {
+		_, h := setup(t, "/*.html Cross-Origin-Opener-Policy: same-origin", "/* ! X-Frame-Options")
+		got := get(h, "/index.html")
+		if got.Get("Cross-Origin-Opener-Policy") != "same-origin" || got.Get("X-Frame-Options") != "" {
+			t.Fatalf("unexpected headers: %v", got)
+		}
+	})
+
+	t.Run("it should reload rules when the file changes", func(t *testing.T) {
+		headersFile, h := setup(t)
+		get(h, "/")
+		os.WriteFile(headersFile, []byte("/*\n  X-Frame-Options: SAMEORIGIN\n"), 0o644)
+		os.Chtimes(headersFile, time.Now().Add(time.Second), time.Now().Add(time.Second))
+		if got := get(h, "/").Get("X-Frame-Options"); got != "SAMEORIGIN" {
+			t.Fatalf("expected reloaded rule, got: %v", got)
+		}
+	})
+
+	t.Run("it should return error on invalid entries", func(t *testing.T) {
+		if _, err := newHeaderRules("", []string{"no-pattern"}, 0); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func Test_adjustCSP(t *testing.T) {
+	ws := "ws://localhost:8080"
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{
+			policy: "default-src 'none'",
+			want:   "default-src 'none'; script-src 'self'; connect-src 'self' ws://localhost:8080",
+		},
+		{
+			policy: "script-src https://cdn.example.com; connect-src 'self'",
+			want:   "script-src https://cdn.example.com 'self'; connect-src 'self' ws://localhost:8080",
+		},
+		{
+			policy: "img-src 'self'",
+			want:   "img-src 'self'",
+		},
+		{
+			policy: "default-src 'self'; script-src-elem 'none'",
+			want:   "default-src 'self'; script-src-elem 'self'; script-src 'self'; connect-src 'self' ws://localhost:8080",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			if got := adjustCSP(tt.policy, ws); got != tt.want {
+				t.Fatalf("expected: '%v', got: '%v'", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_wsSource(t *testing.T) {
+	tests := []struct {
+		host   string
+		wsPort int
+		tls    bool
+		want   string
+	}{
+		{host: "localhost:8080", wsPort: 8080, want: "ws://localhost:8080"},
+		{host: "192.168.1.20:8080", wsPort: 8080, want: "ws://192.168.1.20:8080"},
+		{host: "[::1]:8080", wsPort: 9000, want: "ws://[::1]:9000"},
+		{host: "dev.example.com", wsPort: 0, tls: true, want: "wss://dev.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tt.host
+			if !tt.tls {
+				r.TLS = nil
+			} else if r.TLS == nil {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			hr := &headerRules{wsPort: tt.wsPort}
+			if got := hr.wsSource(r); got != tt.want {
+				t.Fatalf("expected: '%v', got: '%v'", tt.want, got)
+			}
+		})
+	}
+}
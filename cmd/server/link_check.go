@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/internal/linkcheck"
+	"github.com/pchchv/sws/livereload"
+)
+
+// linkCheckDelay is how long the link checker waits for changes to settle before checking.
+const linkCheckDelay = 200 * time.Millisecond
+
+// linkChecker checks the served directory for broken references whenever it changed, and reports
+// the ones which are new since the previous check, as well as the ones which were fixed. The sources
+// are checked rather than the mirror, so that issues point at the lines as they were written.
+type linkChecker struct {
+	masterPath string
+	opts       []linkcheck.Option
+	changes    chan struct{}
+	// reported are the issues of the previous check
+	reported map[linkcheck.Issue]bool
+}
+
+func newLinkChecker(masterPath string, opts ...linkcheck.Option) *linkChecker {
+	return &linkChecker{
+		masterPath: masterPath,
+		opts:       opts,
+		changes:    make(chan struct{}, 1),
+		reported:   make(map[linkcheck.Issue]bool),
+	}
+}
+
+// watcher returns w, which additionally schedules a check on every change.
+func (lc *linkChecker) watcher(w livereload.Watcher) livereload.Watcher {
+	return livereload.WatcherFunc(func(ctx context.Context, notify func(urlPath string)) error {
+		return w.Watch(ctx, func(urlPath string) {
+			notify(urlPath)
+			select {
+			case lc.changes <- struct{}{}:
+			default:
+			}
+		})
+	})
+}
+
+// run checks once, and then after changes, until ctx is done.
+func (lc *linkChecker) run(ctx context.Context) {
+	lc.check()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-lc.changes:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(linkCheckDelay):
+		}
+
+		// changes within the delay are covered by this check
+		select {
+		case <-lc.changes:
+		default:
+		}
+		lc.check()
+	}
+}
+
+func (lc *linkChecker) check() {
+	issues, err := linkcheck.Check(lc.masterPath, lc.opts...)
+	if err != nil {
+		ancli.Err("failed to check links", "err", err)
+		return
+	}
+
+	current := make(map[linkcheck.Issue]bool, len(issues))
+	for _, issue := range issues {
+		current[issue] = true
+		if lc.reported[issue] {
+			continue
+		}
+
+		file := filepath.Join(lc.masterPath, filepath.FromSlash(issue.Path))
+		ancli.Warn("broken reference", "at", fmt.Sprintf("%v:%v", file, issue.Line), "kind", issue.Kind, "ref", issue.Ref, "problem", issue.Msg)
+		issue.Emit(file)
+	}
+
+	fixed := 0
+	for issue := range lc.reported {
+		if !current[issue] {
+			fixed++
+		}
+	}
+	if fixed > 0 {
+		ancli.OK("broken references fixed", "fixed", fixed, "remaining", len(current))
+	}
+	lc.reported = current
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pchchv/sws/internal/events"
+	"github.com/pchchv/sws/internal/linkcheck"
+	"github.com/pchchv/sws/livereload"
+)
+
+func Test_linkChecker(t *testing.T) {
+	t.Run("it should only report new broken references", func(t *testing.T) {
+		var buf bytes.Buffer
+		events.Enable(&buf)
+		t.Cleanup(events.Disable)
+		root := t.TempDir()
+		page := filepath.Join(root, "index.html")
+		os.WriteFile(page, []byte(`<a href="missing.html">`), 0o644)
+		lc := newLinkChecker(root)
+
+		lc.check()
+		lc.check()
+		if got := strings.Count(buf.String(), `"type":"broken_link"`); got != 1 {
+			t.Fatalf("expected the broken reference to be reported once, got: %v events: %v", got, buf.String())
+		}
+
+		if !strings.Contains(buf.String(), `"ref":"missing.html"`) {
+			t.Fatalf("expected the reference in the event, got: %v", buf.String())
+		}
+
+		os.WriteFile(page, []byte(`<a href="index.html">`), 0o644)
+		lc.check()
+		if len(lc.reported) != 0 {
+			t.Fatalf("expected no remaining issues, got: %v", lc.reported)
+		}
+	})
+
+	t.Run("it should report the source file and line of templates with includes", func(t *testing.T) {
+		var buf bytes.Buffer
+		events.Enable(&buf)
+		t.Cleanup(events.Disable)
+		root := t.TempDir()
+		os.MkdirAll(filepath.Join(root, "partials"), 0o755)
+		os.WriteFile(filepath.Join(root, "partials", "nav.html"), []byte("<nav>\n<a href=\"/blog.html\">blog</a>\n</nav>"), 0o644)
+		os.WriteFile(filepath.Join(root, "about.tmpl.html"), []byte("<!--#include virtual=\"/partials/nav.html\" -->\n<a href=\"/blog.html\">blog</a>\n<a href=\"/gone.html\">gone</a>"), 0o644)
+		os.WriteFile(filepath.Join(root, "blog.tmpl.html"), []byte(`<a href="/about.html">about</a>`), 0o644)
+		lc := newLinkChecker(root, linkcheck.WithIncludes(), linkcheck.WithTemplates(filepath.Join(root, "_layouts"), filepath.Join(root, "_data")))
+
+		lc.check()
+		if len(lc.reported) != 1 {
+			t.Fatalf("expected one broken reference, got: %v", lc.reported)
+		}
+
+		file, _ := json.Marshal(filepath.Join(root, "about.tmpl.html"))
+		if want := `"file":` + string(file); !strings.Contains(buf.String(), want) || !strings.Contains(buf.String(), `"line":3`) {
+			t.Fatalf("expected the event to point at: %v on line 3, got: %v", want, buf.String())
+		}
+	})
+
+	t.Run("it should schedule a check on changes", func(t *testing.T) {
+		lc := newLinkChecker(t.TempDir())
+		var notified []string
+		w := lc.watcher(livereload.WatcherFunc(func(ctx context.Context, notify func(urlPath string)) error {
+			notify("/index.html")
+			notify("/style.css")
+			return nil
+		}))
+		w.Watch(context.Background(), func(urlPath string) {
+			notified = append(notified, urlPath)
+		})
+
+		if len(notified) != 2 {
+			t.Fatalf("expected changes to be passed on, got: %v", notified)
+		}
+
+		select {
+		case <-lc.changes:
+		case <-time.After(time.Second):
+			t.Fatal("expected a check to be scheduled")
+		}
+	})
+}
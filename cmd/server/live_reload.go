@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/internal/events"
+	"github.com/pchchv/sws/livereload"
+)
+
+// ancliHandler is a slog.Handler which prints with ancli, so that the logs of embedded
+// packages look like the ones of sws. Groups are flattened.
+type ancliHandler struct {
+	attrs []any
+}
+
+func (h ancliHandler) Enabled(context.Context, slog.Level) bool {
+	// ancli filters by the configured level
+	return true
+}
+
+func (h ancliHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := slices.Clone(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	switch {
+	case r.Level >= slog.LevelError:
+		ancli.Err(r.Message, attrs...)
+	case r.Level >= slog.LevelWarn:
+		ancli.Warn(r.Message, attrs...)
+	case r.Level >= slog.LevelInfo:
+		ancli.OK(r.Message, attrs...)
+	default:
+		ancli.Debug(r.Message, attrs...)
+	}
+	return nil
+}
+
+func (h ancliHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := slices.Clone(h.attrs)
+	for _, a := range attrs {
+		merged = append(merged, a)
+	}
+	return ancliHandler{attrs: merged}
+}
+
+func (h ancliHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// emitLiveReloadEvent emits the event of the live reload as the event of sws.
+func emitLiveReloadEvent(ev livereload.Event) {
+	switch ev.Type {
+	case livereload.ClientConnected:
+		events.Emit(events.Event{Type: events.ClientConnected, Client: ev.Client})
+	case livereload.ClientDisconnected:
+		events.Emit(events.Event{Type: events.ClientDisconnected, Client: ev.Client})
+	case livereload.UpdateSent:
+		events.Emit(events.Event{Type: events.ReloadSent, Path: ev.Path, Clients: &ev.Clients})
+	case livereload.SendFailed:
+		events.Emit(events.Event{Type: events.Error, Client: ev.Client, Path: ev.Path, Error: ev.Err.Error()})
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pchchv/sws/internal/events"
+	"github.com/pchchv/sws/livereload"
+)
+
+func Test_emitLiveReloadEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		ev   livereload.Event
+		want string
+	}{
+		{
+			name: "it should emit sent updates with the amount of clients",
+			ev:   livereload.Event{Type: livereload.UpdateSent, Path: "/index.html", Clients: 2},
+			want: `"path":"/index.html","clients":2`,
+		},
+		{
+			name: "it should emit failed sends as errors",
+			ev:   livereload.Event{Type: livereload.SendFailed, Client: "127.0.0.1:1234", Path: "/index.html", Err: errors.New("broken pipe")},
+			want: `"error":"broken pipe"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			events.Enable(&buf)
+			t.Cleanup(events.Disable)
+			emitLiveReloadEvent(tc.ev)
+			if !strings.Contains(buf.String(), tc.want) {
+				t.Fatalf("expected event containing: %v, got: %v", tc.want, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/livereload"
+)
+
+const (
+	defaultMocksDir = "__mocks__"
+	// externalMocksPrefix is the url path prefix changes to fixtures outside of the served directory are reported below
+	externalMocksPrefix = "/.sws-mocks/"
+	// anyMethod fixtures respond to every method without a more specific fixture
+	anyMethod      = "ANY"
+	metaFileSuffix = ".meta.json"
+)
+
+var mockMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	anyMethod:          true,
+}
+
+// mockMeta is the optional metadata of a fixture, stored next to it as '<METHOD>.meta.json'.
+type mockMeta struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	// Delay is a duration such as '250ms', the response is delayed by.
+	Delay string `json:"delay"`
+}
+
+// mockRoutes serves mock api responses from fixture files in dir.
+// The url path maps to directories and the method to the fixture file name,
+// such as '<dir>/api/users/GET.json' for 'GET /api/users'.
+// Directories named '[name]' match any single path segment, whose value replaces
+// '{{name}}' in the fixture. Fixtures are read on every request, so edits apply immediately.
+type mockRoutes struct {
+	dir string
+	// reloadPrefix is the url path prefix changes to fixtures are reported below, so that they
+	// reload all pages. Only set if dir is outside of the served directory, which is not watched with it.
+	reloadPrefix string
+}
+
+func (m *mockRoutes) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routeDir, params, found := m.resolve(r.URL.Path)
+		if !found {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fixture, methods := findFixture(routeDir, r.Method)
+		if fixture == "" {
+			if len(methods) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			http.Error(w, "method not allowed by mock", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := serveFixture(w, r, fixture, params); err != nil {
+			ancli.Err("failed to serve mock", "path", r.URL.Path, "fixture", fixture, "err", err)
+			http.Error(w, fmt.Sprintf("failed to serve mock fixture: '%v', err: %v", fixture, err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// serves reports if urlPath is a mocked path, which is answered by the mocks for some method.
+func (m *mockRoutes) serves(urlPath string) bool {
+	if m == nil {
+		return false
+	}
+
+	routeDir, _, found := m.resolve(urlPath)
+	if !found {
+		return false
+	}
+	_, methods := findFixture(routeDir, "")
+	return len(methods) > 0
+}
+
+// watcher returns w, which additionally reports changes to the fixtures below reloadPrefix,
+// if the mocks are outside of the served directory.
+func (m *mockRoutes) watcher(w livereload.Watcher) livereload.Watcher {
+	if m == nil || m.reloadPrefix == "" {
+		return w
+	}
+
+	fixtures := livereload.NewDirWatcher(m.dir)
+	return livereload.WatcherFunc(func(ctx context.Context, notify func(urlPath string)) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		errChan := make(chan error, 2)
+		go func() {
+			errChan <- fixtures.Watch(ctx, func(urlPath string) {
+				notify(strings.TrimSuffix(m.reloadPrefix, "/") + urlPath)
+			})
+		}()
+		go func() {
+			errChan <- w.Watch(ctx, notify)
+		}()
+
+		// both watchers have to stop before returning, as the mirror is cleaned up after
+		err := <-errChan
+		cancel()
+		if otherErr := <-errChan; err == nil {
+			err = otherErr
+		}
+		return err
+	})
+}
+
+// resolve finds the fixture directory of urlPath, preferring exact directory names
+// over '[name]' placeholder directories.
+func (m *mockRoutes) resolve(urlPath string) (string, map[string]string, bool) {
+	info, err := os.Stat(m.dir)
+	if err != nil || !info.IsDir() {
+		return "", nil, false
+	}
+
+	current := m.dir
+	params := map[string]string{}
+	for _, segment := range strings.Split(strings.Trim(path.Clean("/"+urlPath), "/"), "/") {
+		if segment == "" {
+			continue
+		}
+
+		if info, err := os.Stat(filepath.Join(current, segment)); err == nil && info.IsDir() && !isPlaceholder(segment) {
+			current = filepath.Join(current, segment)
+			continue
+		}
+
+		entries, err := os.ReadDir(current)
+		if err != nil {
+			return "", nil, false
+		}
+
+		matched := false
+		for _, e := range entries {
+			if e.IsDir() && isPlaceholder(e.Name()) {
+				params[strings.Trim(e.Name(), "[]")] = segment
+				current = filepath.Join(current, e.Name())
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", nil, false
+		}
+	}
+	return current, params, true
+}
+
+func isPlaceholder(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]")
+}
+
+// findFixture returns the fixture in dir for method, falling back to the ANY fixture.
+// The fixture is either a body file, such as 'GET.json', or only a 'GET.meta.json' file.
+// All methods with fixtures in dir are returned as well.
+func findFixture(dir, method string) (string, []string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil
+	}
+
+	fixtures := map[string]string{}
+	var methods []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		name := e.Name()
+		fixtureMethod, _, _ := strings.Cut(name, ".")
+		if !mockMethods[fixtureMethod] {
+			continue
+		}
+
+		if existing, ok := fixtures[fixtureMethod]; !ok || strings.HasSuffix(existing, metaFileSuffix) {
+			fixtures[fixtureMethod] = filepath.Join(dir, name)
+		}
+		if !slices.Contains(methods, fixtureMethod) {
+			methods = append(methods, fixtureMethod)
+		}
+	}
+	slices.Sort(methods)
+
+	if f, ok := fixtures[method]; ok {
+		return f, methods
+	}
+	if method == http.MethodHead {
+		if f, ok := fixtures[http.MethodGet]; ok {
+			return f, methods
+		}
+	}
+	return fixtures[anyMethod], methods
+}
+
+// paramValue returns the value of a path parameter as it is inserted into a fixture. In json
+// fixtures it is escaped as the content of a string, so that quotes and backslashes keep it valid.
+func paramValue(value string, isJSON bool) []byte {
+	if !isJSON {
+		return []byte(value)
+	}
+	b, _ := json.Marshal(value)
+	return b[1 : len(b)-1]
+}
+
+func serveFixture(w http.ResponseWriter, r *http.Request, fixture string, params map[string]string) error {
+	var body []byte
+	metaPath := fixture
+	if !strings.HasSuffix(fixture, metaFileSuffix) {
+		b, err := os.ReadFile(fixture)
+		if err != nil {
+			return err
+		}
+		body = b
+		metaPath = strings.TrimSuffix(fixture, filepath.Ext(fixture)) + metaFileSuffix
+	}
+
+	meta := mockMeta{Status: http.StatusOK}
+	if b, err := os.ReadFile(metaPath); err == nil {
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return fmt.Errorf("failed to parse meta file: '%v', err: %v", metaPath, err)
+		}
+	}
+
+	if meta.Delay != "" {
+		delay, err := time.ParseDuration(meta.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid delay in meta file: '%v', err: %v", metaPath, err)
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+
+	if body != nil {
+		isJSON := strings.EqualFold(filepath.Ext(fixture), ".json")
+		for name, value := range params {
+			body = bytes.ReplaceAll(body, []byte("{{"+name+"}}"), paramValue(value, isJSON))
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(fixture))
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	for name, value := range meta.Headers {
+		w.Header().Set(name, value)
+	}
+
+	if meta.Status == 0 {
+		meta.Status = http.StatusOK
+	}
+	w.WriteHeader(meta.Status)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+	return nil
+}
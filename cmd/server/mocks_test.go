@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pchchv/sws/livereload"
+)
+
+func Test_mockRoutes(t *testing.T) {
+	setup := func(t *testing.T) (string, http.Handler) {
+		t.Helper()
+		dir := t.TempDir()
+		write := func(rel, content string) {
+			p := filepath.Join(dir, filepath.FromSlash(rel))
+			os.MkdirAll(filepath.Dir(p), 0o755)
+			if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+				t.Fatalf("failed to write fixture: %v", err)
+			}
+		}
+		write("api/users/GET.json", `[{"id":1}]`)
+		write("api/users/POST.json", `{"id":2}`)
+		write("api/users/POST.meta.json", `{"status":201,"headers":{"Location":"/api/users/2"}}`)
+		write("api/users/[id]/GET.json", `{"id":"{{id}}"}`)
+		write("api/users/[id]/DELETE.meta.json", `{"status":204}`)
+		write("api/slow/ANY.txt", `slow`)
+		write("api/slow/ANY.meta.json", `{"delay":"20ms"}`)
+		write("api/users/README.md", `not a fixture`)
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "from file server", http.StatusTeapot)
+		})
+		return dir, (&mockRoutes{dir: dir}).handler(next)
+	}
+
+	do := func(h http.Handler, method, target string) *http.Response {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec.Result()
+	}
+
+	body := func(resp *http.Response) string {
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	t.Run("it should serve fixture of method", func(t *testing.T) {
+		_, h := setup(t)
+		resp := do(h, http.MethodGet, "/api/users")
+		if resp.StatusCode != http.StatusOK || body(resp) != `[{"id":1}]` {
+			t.Fatalf("unexpected response: %v", resp.Status)
+		}
+
+		if got := resp.Header.Get("Content-Type"); got != "application/json" {
+			t.Fatalf("expected json content type, got: '%v'", got)
+		}
+	})
+
+	t.Run("it should apply status and headers of meta file", func(t *testing.T) {
+		_, h := setup(t)
+		resp := do(h, http.MethodPost, "/api/users/")
+		if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/api/users/2" {
+			t.Fatalf("unexpected response: %v, headers: %v", resp.Status, resp.Header)
+		}
+	})
+
+	t.Run("it should match path parameters", func(t *testing.T) {
+		_, h := setup(t)
+		if got := body(do(h, http.MethodGet, "/api/users/42")); got != `{"id":"42"}` {
+			t.Fatalf("unexpected body: %v", got)
+		}
+
+		if got := do(h, http.MethodDelete, "/api/users/42"); got.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected meta only fixture to respond 204, got: %v", got.Status)
+		}
+	})
+
+	t.Run("it should escape path parameters in json fixtures", func(t *testing.T) {
+		_, h := setup(t)
+		got := body(do(h, http.MethodGet, `/api/users/a%22b%5Cc`))
+		if want := `{"id":"a\"b\\c"}`; got != want {
+			t.Fatalf("expected: %v, got: %v", want, got)
+		}
+
+		var v map[string]string
+		if err := json.Unmarshal([]byte(got), &v); err != nil || v["id"] != `a"b\c` {
+			t.Fatalf("expected valid json with the parameter, got: %v, err: %v", v, err)
+		}
+	})
+
+	t.Run("it should respond 405 with allowed methods on unmatched method", func(t *testing.T) {
+		_, h := setup(t)
+		resp := do(h, http.MethodPut, "/api/users")
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, POST" {
+			t.Fatalf("unexpected response: %v, allow: '%v'", resp.Status, resp.Header.Get("Allow"))
+		}
+	})
+
+	t.Run("it should delay the any fixture", func(t *testing.T) {
+		_, h := setup(t)
+		start := time.Now()
+		resp := do(h, http.MethodPatch, "/api/slow")
+		if body(resp) != "slow" || time.Since(start) < 20*time.Millisecond {
+			t.Fatalf("expected delayed response, got: %v after: %v", resp.Status, time.Since(start))
+		}
+	})
+
+	t.Run("it should pass unmatched paths to the next handler", func(t *testing.T) {
+		_, h := setup(t)
+		for _, target := range []string{"/index.html", "/api", "/api/users/1/comments"} {
+			if got := do(h, http.MethodGet, target); got.StatusCode != http.StatusTeapot {
+				t.Fatalf("expected: '%v' to be passed on, got: %v", target, got.Status)
+			}
+		}
+	})
+
+	t.Run("it should read fixture changes on every request", func(t *testing.T) {
+		dir, h := setup(t)
+		os.WriteFile(filepath.Join(dir, "api", "users", "GET.json"), []byte(`[]`), 0o644)
+		if got := body(do(h, http.MethodGet, "/api/users")); got != `[]` {
+			t.Fatalf("expected updated fixture, got: %v", got)
+		}
+	})
+}
+
+func Test_mockRoutes_watcher(t *testing.T) {
+	t.Run("it should report fixture changes outside of the served directory", func(t *testing.T) {
+		dir := t.TempDir()
+		m := &mockRoutes{dir: dir, reloadPrefix: externalMocksPrefix}
+		site := livereload.WatcherFunc(func(ctx context.Context, notify func(urlPath string)) error {
+			<-ctx.Done()
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		got := make(chan string, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- m.watcher(site).Watch(ctx, func(urlPath string) {
+				select {
+				case got <- urlPath:
+				default:
+				}
+			})
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		os.WriteFile(filepath.Join(dir, "GET.json"), []byte(`{}`), 0o644)
+		select {
+		case p := <-got:
+			if p != "/.sws-mocks/GET.json" {
+				t.Fatalf("expected change below the mocks prefix, got: %v", p)
+			}
+		case <-ctx.Done():
+			t.Fatal("expected the fixture change to be reported")
+		}
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("expected watchers to stop without error, got: %v", err)
+		}
+	})
+
+	t.Run("it should not wrap the watcher of mocks inside of the served directory", func(t *testing.T) {
+		site := livereload.WatcherFunc(func(ctx context.Context, notify func(urlPath string)) error {
+			return errors.New("site")
+		})
+		if err := (&mockRoutes{dir: t.TempDir()}).watcher(site).Watch(context.Background(), nil); err == nil || err.Error() != "site" {
+			t.Fatalf("expected the watcher to be returned as is, got: %v", err)
+		}
+	})
+}
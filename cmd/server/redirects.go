@@ -0,0 +1,282 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pchchv/sws/helpers/ancli"
+)
+
+const redirectsFileName = "_redirects"
+
+type redirectRule struct {
+	from *urlPattern
+	// query holds required query parameters, mapped to the placeholder their value binds to
+	query  map[string]string
+	to     string
+	status int
+	// force applies the rule even if a file exists on the from path
+	force bool
+}
+
+var toPlaceholderRe = regexp.MustCompile(`:[A-Za-z_][A-Za-z0-9_]*`)
+
+// parseRedirectLine parses a netlify style redirect rule:
+//
+//	<from> [<query param>=:<placeholder>...] <to> [<status>[!]]
+//
+// The status defaults to 301. 200 rewrites, 404 serves to with status 404,
+// and a trailing '!' forces the rule even if a file exists on the from path.
+func parseRedirectLine(line string) (redirectRule, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return redirectRule{}, fmt.Errorf("invalid rule: '%v', expected '<from> <to> [status]'", line)
+	}
+
+	from, err := compileURLPattern(fields[0])
+	if err != nil {
+		return redirectRule{}, err
+	}
+
+	rule := redirectRule{from: from, status: http.StatusMovedPermanently}
+	rest := fields[1:]
+	for len(rest) > 0 && !strings.HasPrefix(rest[0], "/") && !strings.Contains(rest[0], "://") {
+		key, value, found := strings.Cut(rest[0], "=")
+		if !found {
+			return redirectRule{}, fmt.Errorf("invalid query condition: '%v', expected '<param>=:<placeholder>'", rest[0])
+		}
+		if rule.query == nil {
+			rule.query = map[string]string{}
+		}
+		rule.query[key] = strings.TrimPrefix(value, ":")
+		rest = rest[1:]
+	}
+
+	if len(rest) == 0 {
+		return redirectRule{}, fmt.Errorf("invalid rule: '%v', missing target", line)
+	}
+	rule.to = rest[0]
+	rest = rest[1:]
+
+	if len(rest) > 0 {
+		status, force := strings.CutSuffix(rest[0], "!")
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return redirectRule{}, fmt.Errorf("invalid status: '%v'", rest[0])
+		}
+		rule.status, rule.force = code, force
+		rest = rest[1:]
+	}
+
+	switch {
+	case rule.status == http.StatusOK, rule.status == http.StatusNotFound,
+		rule.status >= 300 && rule.status < 400:
+	default:
+		return redirectRule{}, fmt.Errorf("unsupported status: %v", rule.status)
+	}
+
+	if len(rest) > 0 {
+		return redirectRule{}, fmt.Errorf("unsupported conditions: '%v'", strings.Join(rest, " "))
+	}
+	return rule, nil
+}
+
+// parseRedirectRules parses a netlify style _redirects file with one rule per line.
+// Rules which fail to parse are skipped with an error message, as a host would.
+func parseRedirectRules(b []byte) ([]redirectRule, error) {
+	var rules []redirectRule
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	lineNr := 0
+	for scanner.Scan() {
+		lineNr++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRedirectLine(line)
+		if err != nil {
+			ancli.Err("skipping redirect rule", "line", lineNr, "err", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// redirectRules applies the first matching rule of the redirects file,
+// or else of the rules given as config entries.
+type redirectRules struct {
+	file    *reloadingFile[[]redirectRule]
+	entries []redirectRule
+	// root is checked for existing files, which shadow unforced rules
+	root string
+	// mocks are checked for mocked paths, which shadow unforced rules as well
+	mocks *mockRoutes
+}
+
+func newRedirectRules(filePath string, entries []string, root string) (*redirectRules, error) {
+	rr := &redirectRules{
+		file: &reloadingFile[[]redirectRule]{
+			path:  filePath,
+			parse: parseRedirectRules,
+		},
+		root: root,
+	}
+	for _, entry := range entries {
+		rule, err := parseRedirectLine(entry)
+		if err != nil {
+			return nil, err
+		}
+		rr.entries = append(rr.entries, rule)
+	}
+	return rr, nil
+}
+
+// match returns the first matching rule and the target with placeholders replaced.
+func (rr *redirectRules) match(r *http.Request) (redirectRule, string, bool) {
+	query := r.URL.Query()
+	for _, rules := range [][]redirectRule{rr.file.get(), rr.entries} {
+	rules:
+		for _, rule := range rules {
+			params, ok := rule.from.match(r.URL.Path)
+			if !ok {
+				continue
+			}
+
+			for key, placeholder := range rule.query {
+				if !query.Has(key) {
+					continue rules
+				}
+				params[placeholder] = query.Get(key)
+			}
+
+			if !rule.force && rr.exists(r.URL.Path) {
+				continue
+			}
+
+			to := toPlaceholderRe.ReplaceAllStringFunc(rule.to, func(placeholder string) string {
+				if value, ok := params[placeholder[1:]]; ok {
+					return value
+				}
+				return placeholder
+			})
+			return rule, to, true
+		}
+	}
+	return redirectRule{}, "", false
+}
+
+// exists reports if urlPath would be served by the mocks or the file server.
+func (rr *redirectRules) exists(urlPath string) bool {
+	if rr.mocks.serves(urlPath) {
+		return true
+	}
+
+	p := filepath.Join(rr.root, filepath.FromSlash(path.Clean("/"+urlPath)))
+	info, err := os.Stat(p)
+	if err != nil {
+		return false
+	}
+
+	if info.IsDir() {
+		_, err = os.Stat(filepath.Join(p, "index.html"))
+		return err == nil
+	}
+	return true
+}
+
+func (rr *redirectRules) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, to, found := rr.match(r)
+		if !found {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		target, err := url.Parse(to)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid redirect target: '%v'", to), http.StatusInternalServerError)
+			return
+		}
+
+		// the original query is passed on unless the target has its own
+		if target.RawQuery == "" && len(rule.query) == 0 {
+			target.RawQuery = r.URL.RawQuery
+		}
+
+		switch {
+		case rule.status >= 300 && rule.status < 400:
+			http.Redirect(w, r, target.String(), rule.status)
+		case target.IsAbs():
+			proxy(target).ServeHTTP(w, r)
+		case rule.status == http.StatusNotFound:
+			next.ServeHTTP(&statusOverrideWriter{ResponseWriter: w, status: http.StatusNotFound}, rewrite(r, target))
+		default:
+			next.ServeHTTP(w, rewrite(r, target))
+		}
+	})
+}
+
+func rewrite(r *http.Request, target *url.URL) *http.Request {
+	rewritten := r.Clone(r.Context())
+	rewritten.URL.Path = target.Path
+	// http.FileServer redirects requests for index.html to the directory
+	if strings.HasSuffix(target.Path, "/index.html") {
+		rewritten.URL.Path = strings.TrimSuffix(target.Path, "index.html")
+	}
+	rewritten.URL.RawPath = ""
+	rewritten.URL.RawQuery = target.RawQuery
+	rewritten.RequestURI = rewritten.URL.RequestURI()
+	return rewritten
+}
+
+func proxy(target *url.URL) http.Handler {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = target
+			pr.Out.Host = target.Host
+			pr.SetXForwarded()
+		},
+	}
+}
+
+// statusOverrideWriter replaces successful statuses with status.
+type statusOverrideWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusOverrideWriter) WriteHeader(status int) {
+	if sw.wroteHeader {
+		return
+	}
+	sw.wroteHeader = true
+	if status == http.StatusOK {
+		status = sw.status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusOverrideWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (sw *statusOverrideWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+const testRedirectsFile = `# comment
+/old-page            /new-page
+/blog/:year/:slug    /posts/:year-:slug     302
+/docs/*              /documentation/:splat  308
+/search q=:query     /find?term=:query      301
+/existing.html       /elsewhere             301
+/forced.html         /elsewhere             301!
+/api/*               /mock-api/:splat       200
+/app/*               /app/index.html        200
+/*                   /404.html              404
+/broken              /target  Country=us
+`
+
+func Test_parseRedirectLine(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantStatus int
+		wantForce  bool
+		wantErr    bool
+	}{
+		{line: "/a /b", wantStatus: 301},
+		{line: "/a /b 302", wantStatus: 302},
+		{line: "/a /b 200!", wantStatus: 200, wantForce: true},
+		{line: "/a https://example.com/b 200", wantStatus: 200},
+		{line: "/a", wantErr: true},
+		{line: "/a /b abc", wantErr: true},
+		{line: "/a /b 500", wantErr: true},
+		{line: "/a /b 302 Role=admin", wantErr: true},
+		{line: "a /b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseRedirectLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+
+			if err == nil && (got.status != tt.wantStatus || got.force != tt.wantForce) {
+				t.Fatalf("expected status: %v force: %v, got: %+v", tt.wantStatus, tt.wantForce, got)
+			}
+		})
+	}
+}
+
+func Test_redirectRules(t *testing.T) {
+	setup := func(t *testing.T, entries ...string) (string, http.Handler) {
+		t.Helper()
+		root := t.TempDir()
+		os.WriteFile(path.Join(root, "existing.html"), []byte("existing"), 0o644)
+		os.WriteFile(path.Join(root, "forced.html"), []byte("forced"), 0o644)
+		os.WriteFile(path.Join(root, "404.html"), []byte("custom not found"), 0o644)
+		os.MkdirAll(path.Join(root, "app"), 0o755)
+		os.WriteFile(path.Join(root, "app", "index.html"), []byte("spa"), 0o644)
+		os.MkdirAll(path.Join(root, "mock-api", "users"), 0o755)
+		os.WriteFile(path.Join(root, "mock-api", "users", "index.html"), []byte("users"), 0o644)
+		redirectsFile := path.Join(root, redirectsFileName)
+		os.WriteFile(redirectsFile, []byte(testRedirectsFile), 0o644)
+		rr, err := newRedirectRules(redirectsFile, entries, root)
+		if err != nil {
+			t.Fatalf("failed to create redirect rules: %v", err)
+		}
+		return redirectsFile, rr.handler(http.FileServer(http.Dir(root)))
+	}
+
+	get := func(h http.Handler, target string) *http.Response {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Result()
+	}
+
+	body := func(resp *http.Response) string {
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	redirectTests := []struct {
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{target: "/old-page", wantStatus: 301, wantLocation: "/new-page"},
+		{target: "/old-page?ref=a", wantStatus: 301, wantLocation: "/new-page?ref=a"},
+		{target: "/blog/2024/hello", wantStatus: 302, wantLocation: "/posts/2024-hello"},
+		{target: "/docs/guide/intro", wantStatus: 308, wantLocation: "/documentation/guide/intro"},
+		{target: "/search?q=shoes", wantStatus: 301, wantLocation: "/find?term=shoes"},
+		{target: "/forced.html", wantStatus: 301, wantLocation: "/elsewhere"},
+	}
+	for _, tt := range redirectTests {
+		t.Run("it should redirect "+tt.target, func(t *testing.T) {
+			_, h := setup(t)
+			resp := get(h, tt.target)
+			if resp.StatusCode != tt.wantStatus || resp.Header.Get("Location") != tt.wantLocation {
+				t.Fatalf("expected: %v %v, got: %v %v", tt.wantStatus, tt.wantLocation, resp.StatusCode, resp.Header.Get("Location"))
+			}
+		})
+	}
+
+	t.Run("it should not apply unforced rules to existing files", func(t *testing.T) {
+		_, h := setup(t)
+		if resp := get(h, "/existing.html"); resp.StatusCode != http.StatusOK || body(resp) != "existing" {
+			t.Fatalf("expected existing file to shadow the rule, got: %v", resp.Status)
+		}
+	})
+
+	t.Run("it should rewrite with status 200", func(t *testing.T) {
+		_, h := setup(t)
+		if resp := get(h, "/api/users/"); resp.StatusCode != http.StatusOK || body(resp) != "users" {
+			t.Fatalf("expected rewritten response, got: %v", resp.Status)
+		}
+
+		if resp := get(h, "/app/some/route"); resp.StatusCode != http.StatusOK || body(resp) != "spa" {
+			t.Fatalf("expected spa fallback, got: %v", resp.Status)
+		}
+	})
+
+	t.Run("it should serve custom 404 page", func(t *testing.T) {
+		_, h := setup(t)
+		if resp := get(h, "/missing"); resp.StatusCode != http.StatusNotFound || body(resp) != "custom not found" {
+			t.Fatalf("expected custom 404, got: %v", resp.Status)
+		}
+	})
+
+	t.Run("it should apply config entries after the file", func(t *testing.T) {
+		_, h := setup(t, "/entry /from-entry 307")
+		if resp := get(h, "/old-page"); resp.Header.Get("Location") != "/new-page" {
+			t.Fatalf("expected file rule to win, got: %v", resp.Header.Get("Location"))
+		}
+	})
+
+	t.Run("it should proxy to absolute urls", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("upstream " + r.URL.Path))
+		}))
+		t.Cleanup(upstream.Close)
+		rr, err := newRedirectRules(path.Join(t.TempDir(), redirectsFileName), []string{"/proxy/* " + upstream.URL + "/v1/:splat 200"}, t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create redirect rules: %v", err)
+		}
+
+		if got := body(get(rr.handler(http.NotFoundHandler()), "/proxy/items")); got != "upstream /v1/items" {
+			t.Fatalf("expected proxied response, got: '%v'", got)
+		}
+	})
+
+	t.Run("it should not apply unforced rules to mocked paths", func(t *testing.T) {
+		root, mocksDir := t.TempDir(), t.TempDir()
+		os.WriteFile(path.Join(root, "index.html"), []byte("spa"), 0o644)
+		os.MkdirAll(path.Join(mocksDir, "api", "users"), 0o755)
+		os.WriteFile(path.Join(mocksDir, "api", "users", "GET.json"), []byte(`[]`), 0o644)
+		rr, err := newRedirectRules(path.Join(root, redirectsFileName), []string{"/* /index.html 200"}, root)
+		if err != nil {
+			t.Fatalf("failed to create redirect rules: %v", err)
+		}
+		rr.mocks = &mockRoutes{dir: mocksDir}
+		h := rr.handler(rr.mocks.handler(http.FileServer(http.Dir(root))))
+
+		if got := body(get(h, "/api/users")); got != `[]` {
+			t.Fatalf("expected the mocked response, got: '%v'", got)
+		}
+
+		if got := body(get(h, "/some/route")); got != "spa" {
+			t.Fatalf("expected the spa fallback, got: '%v'", got)
+		}
+	})
+
+	t.Run("it should reload rules when the file changes", func(t *testing.T) {
+		redirectsFile, h := setup(t)
+		get(h, "/")
+		os.WriteFile(redirectsFile, []byte("/old-page /newer-page 302\n"), 0o644)
+		os.Chtimes(redirectsFile, time.Now().Add(time.Second), time.Now().Add(time.Second))
+		if resp := get(h, "/old-page"); resp.Header.Get("Location") != "/newer-page" {
+			t.Fatalf("expected reloaded rule, got: %v", resp.Header.Get("Location"))
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pchchv/sws/helpers/ancli"
+)
+
+// urlPattern matches url paths against netlify style patterns.
+// A '*' matches anything, including slashes, and ':name' matches a single path segment.
+type urlPattern struct {
+	raw    string
+	re     *regexp.Regexp
+	params []string
+}
+
+var placeholderRe = regexp.MustCompile(`^:[A-Za-z_][A-Za-z0-9_]*`)
+
+func compileURLPattern(pattern string) (*urlPattern, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("pattern: '%v' has to start with '/'", pattern)
+	}
+
+	var re strings.Builder
+	var params []string
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*':
+			re.WriteString("(.*)")
+			params = append(params, "splat")
+		case c == ':' && pattern[i-1] == '/':
+			name := placeholderRe.FindString(pattern[i:])
+			if name == "" {
+				re.WriteString(":")
+				continue
+			}
+			re.WriteString("([^/]+)")
+			params = append(params, name[1:])
+			i += len(name) - 1
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// a trailing slash is optional, as is the case on most static hosts
+	re.WriteString("/?$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile pattern: '%v', err: %v", pattern, err)
+	}
+	return &urlPattern{raw: pattern, re: compiled, params: params}, nil
+}
+
+// match reports if urlPath matches the pattern and returns the captured
+// placeholders by name, with the '*' capture named 'splat'.
+func (p *urlPattern) match(urlPath string) (map[string]string, bool) {
+	m := p.re.FindStringSubmatch(urlPath)
+	if m == nil {
+		return nil, false
+	}
+
+	params := make(map[string]string, len(p.params))
+	for i, name := range p.params {
+		params[name] = m[i+1]
+	}
+	return params, true
+}
+
+// reloadingFile holds the parsed content of a file,
+// which is parsed again whenever the file changes on disk.
+type reloadingFile[T any] struct {
+	path    string
+	parse   func([]byte) (T, error)
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	exists  bool
+	value   T
+}
+
+// get returns the parsed content of the file, or the zero value if the file does not exist.
+// If the file fails to parse, the previously parsed content is kept.
+func (rf *reloadingFile[T]) get() T {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	info, err := os.Stat(rf.path)
+	if err != nil {
+		if rf.exists {
+			ancli.Notice("rules file removed", "path", rf.path)
+			var zero T
+			rf.value, rf.exists = zero, false
+		}
+		return rf.value
+	}
+
+	if rf.exists && info.ModTime().Equal(rf.modTime) && info.Size() == rf.size {
+		return rf.value
+	}
+
+	rf.modTime, rf.size, rf.exists = info.ModTime(), info.Size(), true
+	b, err := os.ReadFile(rf.path)
+	if err != nil {
+		ancli.Err("failed to read rules file", "path", rf.path, "err", err)
+		return rf.value
+	}
+
+	value, err := rf.parse(b)
+	if err != nil {
+		ancli.Err("failed to parse rules file", "path", rf.path, "err", err)
+		return rf.value
+	}
+
+	ancli.Notice("loaded rules file", "path", rf.path)
+	rf.value = value
+	return value
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"maps"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_urlPattern(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		path       string
+		wantMatch  bool
+		wantParams map[string]string
+	}{
+		{pattern: "/fonts/*", path: "/fonts/a/b.woff2", wantMatch: true, wantParams: map[string]string{"splat": "a/b.woff2"}},
+		{pattern: "/fonts/*", path: "/img/a.png", wantMatch: false},
+		{pattern: "/*.html", path: "/nested/index.html", wantMatch: true, wantParams: map[string]string{"splat": "nested/index"}},
+		{pattern: "/blog/:year/:slug", path: "/blog/2024/hello", wantMatch: true, wantParams: map[string]string{"year": "2024", "slug": "hello"}},
+		{pattern: "/blog/:year/:slug", path: "/blog/2024/hello/more", wantMatch: false},
+		{pattern: "/about", path: "/about/", wantMatch: true, wantParams: map[string]string{}},
+		{pattern: "/a.b", path: "/axb", wantMatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			p, err := compileURLPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("failed to compile pattern: %v", err)
+			}
+
+			params, ok := p.match(tt.path)
+			if ok != tt.wantMatch {
+				t.Fatalf("expected match: %v, got: %v", tt.wantMatch, ok)
+			}
+
+			if ok && !maps.Equal(params, tt.wantParams) {
+				t.Fatalf("expected params: %v, got: %v", tt.wantParams, params)
+			}
+		})
+	}
+
+	t.Run("it should return error on relative pattern", func(t *testing.T) {
+		if _, err := compileURLPattern("fonts/*"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func Test_reloadingFile(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "rules")
+	rf := &reloadingFile[string]{
+		path: filePath,
+		parse: func(b []byte) (string, error) {
+			return strings.ToUpper(string(b)), nil
+		},
+	}
+
+	if got := rf.get(); got != "" {
+		t.Fatalf("expected zero value for missing file, got: '%v'", got)
+	}
+
+	os.WriteFile(filePath, []byte("first"), 0o644)
+	if got := rf.get(); got != "FIRST" {
+		t.Fatalf("expected: 'FIRST', got: '%v'", got)
+	}
+
+	os.WriteFile(filePath, []byte("second!"), 0o644)
+	os.Chtimes(filePath, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	if got := rf.get(); got != "SECOND!" {
+		t.Fatalf("expected reload to: 'SECOND!', got: '%v'", got)
+	}
+
+	os.Remove(filePath)
+	if got := rf.get(); got != "" {
+		t.Fatalf("expected zero value after removal, got: '%v'", got)
+	}
+}
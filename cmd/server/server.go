@@ -5,31 +5,71 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/internal/events"
+	"github.com/pchchv/sws/internal/linkcheck"
 	"github.com/pchchv/sws/internal/wsinject"
+	"github.com/pchchv/sws/livereload"
 )
 
 type Fileserver interface {
+	livereload.Watcher
 	Setup(pathToMaster string) (string, error)
-	Start(ctx context.Context) error
-	WsHandler(ws *websocket.Conn)
+	Close() error
 }
 
 type command struct {
-	port         *int
-	wsPath       *string
-	binPath      string
-	masterPath   string
-	mirrorPath   string
-	cacheControl *string
-	forceReload  *bool
-	fileserver   Fileserver
-	flagset      *flag.FlagSet
+	port            *int
+	wsPath          *string
+	binPath         string
+	masterPath      string
+	mirrorPath      string
+	cacheControl    *string
+	forceReload     *bool
+	accessLog       *string
+	accessLogFile   *string
+	hideAssets      *bool
+	compress        *bool
+	headersFile     *string
+	headerEntries   []string
+	headerRules     *headerRules
+	mocksDir        *string
+	mocks           *mockRoutes
+	redirectsFile   *string
+	redirectEntries []string
+	redirectRules   *redirectRules
+	basicAuth       *string
+	token           *string
+	allowedOrigins  *string
+	auth            *auth
+	injectInclude   []string
+	injectExclude   []string
+	injectExts      *string
+	injectSniff     *bool
+	poll            *bool
+	pollInterval    *time.Duration
+	pollHash        *bool
+	followSymlinks  *bool
+	persistMirror   *bool
+	includes        *bool
+	templates       *bool
+	morph           *bool
+	sync            *bool
+	checkLinks      *bool
+	layoutsDir      *string
+	dataDir         *string
+	liveReload      *livereload.LiveReload
+	fileserver      Fileserver
+	flagset         *flag.FlagSet
 }
 
 func Command() *command {
@@ -52,8 +92,77 @@ func (c *command) Setup() error {
 	}
 	c.masterPath = path.Clean(relPath)
 
+	if !validAccessLogFormat(*c.accessLog) {
+		return fmt.Errorf("invalid access log format: '%v', expected one of: pretty, common, combined, json", *c.accessLog)
+	}
+
+	lrOpts := []livereload.Option{
+		livereload.WithWsPath(*c.wsPath),
+		livereload.WithWsPort(*c.port),
+		livereload.WithCheckOrigin(checkOrigin(parseOrigins(*c.allowedOrigins))),
+		livereload.WithLogger(slog.New(ancliHandler{})),
+		livereload.WithEventHook(emitLiveReloadEvent),
+	}
+	if *c.forceReload {
+		lrOpts = append(lrOpts, livereload.WithForceReload())
+	}
+	if *c.morph {
+		lrOpts = append(lrOpts, livereload.WithMorph())
+	}
+	if *c.sync {
+		lrOpts = append(lrOpts, livereload.WithSync())
+	}
+
+	fsOpts := []wsinject.Option{
+		wsinject.WithInjectInclude(c.injectInclude...),
+		wsinject.WithInjectExclude(c.injectExclude...),
+		wsinject.WithInjectExtensions(strings.Split(*c.injectExts, ",")...),
+		wsinject.WithInjectSniffing(*c.injectSniff),
+		wsinject.WithPollInterval(*c.pollInterval, *c.pollHash),
+		wsinject.WithIncludes(*c.includes),
+	}
+	if *c.poll {
+		fsOpts = append(fsOpts, wsinject.WithPolling())
+	}
+	if *c.followSymlinks {
+		fsOpts = append(fsOpts, wsinject.WithFollowSymlinks())
+	}
+	if *c.persistMirror {
+		fsOpts = append(fsOpts, wsinject.WithPersistentMirror(""))
+	}
+	if *c.templates {
+		fsOpts = append(fsOpts, wsinject.WithTemplates(c.rootRelative(*c.layoutsDir), c.rootRelative(*c.dataDir)))
+	}
+	auth, err := newAuth(*c.basicAuth, *c.token)
+	if err != nil {
+		return fmt.Errorf("failed to setup authentication: %v", err)
+	}
+	c.auth = auth
+	if auth != nil {
+		lrOpts = append(lrOpts, livereload.WithWsToken(auth.wsToken))
+	}
+
+	if *c.mocksDir != "" {
+		mocksDir := c.rootRelative(*c.mocksDir)
+		c.mocks = &mockRoutes{dir: mocksDir}
+		// fixtures are fetched, not loaded as pages, so any change to them reloads all pages
+		if rel, err := filepath.Rel(c.masterPath, mocksDir); err == nil && !strings.HasPrefix(rel, "..") {
+			lrOpts = append(lrOpts, livereload.WithAlwaysReload("/"+filepath.ToSlash(rel)+"/"))
+		} else if info, err := os.Stat(mocksDir); err == nil && info.IsDir() {
+			// fixtures outside of the served directory are watched separately
+			c.mocks.reloadPrefix = externalMocksPrefix
+			lrOpts = append(lrOpts, livereload.WithAlwaysReload(externalMocksPrefix))
+		}
+	}
+
+	liveReload, err := livereload.New(lrOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to setup live reload: %v", err)
+	}
+	c.liveReload = liveReload
+
 	if c.masterPath != "" {
-		c.fileserver = wsinject.NewFileServer(*c.port, *c.wsPath, *c.forceReload)
+		c.fileserver = wsinject.NewFileServer(liveReload, fsOpts...)
 		mirrorPath, err := c.fileserver.Setup(c.masterPath)
 		if err != nil {
 			return fmt.Errorf("failed to setup websocket injected mirror filesystem: %e", err)
@@ -61,6 +170,45 @@ func (c *command) Setup() error {
 		c.mirrorPath = mirrorPath
 	}
 
+	if err := c.setupRules(); err != nil {
+		if c.fileserver != nil {
+			c.fileserver.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// rootRelative returns p relative to the served directory, unless it is absolute.
+func (c *command) rootRelative(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.masterPath, p)
+}
+
+// setupRules sets up the header and redirect rules, from their files and the config entries.
+func (c *command) setupRules() error {
+	headersFile := *c.headersFile
+	if headersFile == "" {
+		headersFile = path.Join(c.masterPath, headersFileName)
+	}
+	headerRules, err := newHeaderRules(headersFile, c.headerEntries, *c.port)
+	if err != nil {
+		return fmt.Errorf("failed to parse header rules: %v", err)
+	}
+	c.headerRules = headerRules
+
+	redirectsFile := *c.redirectsFile
+	if redirectsFile == "" {
+		redirectsFile = path.Join(c.masterPath, redirectsFileName)
+	}
+	redirectRules, err := newRedirectRules(redirectsFile, c.redirectEntries, c.mirrorPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse redirect rules: %v", err)
+	}
+	redirectRules.mocks = c.mocks
+	c.redirectRules = redirectRules
 	return nil
 }
 
@@ -77,46 +225,144 @@ func (c *command) Flagset() *flag.FlagSet {
 	c.port = fs.Int("port", 8080, "port to serve http server on")
 	c.wsPath = fs.String("wsPort", "/delta-streamer-ws", "the path which the delta streamer websocket should be hosted on")
 	c.forceReload = fs.Bool("forceReload", false, "set to true if you wish to reload all attached browser pages on any file change")
+	c.morph = fs.Bool("morph", false, "set to true to morph the DOM of open pages to match changed html, keeping their state, instead of reloading them. Pages still reload if their scripts changed")
+	c.sync = fs.Bool("sync", false, "set to true to mirror scrolling, navigation, clicks and form input of one browser in all other connected browsers")
+	c.checkLinks = fs.Bool("check", false, "set to true to check the served pages for broken links and assets on every change, and report them in the terminal")
 	c.cacheControl = fs.String("cacheControl", "no-cache", "set to configure the cache-control header")
+	c.accessLog = fs.String("accessLog", accessLogPretty, "format of the access log: pretty, common, combined or json")
+	c.accessLogFile = fs.String("accessLogFile", "", "if set, the access log is also appended to this file")
+	c.hideAssets = fs.Bool("hideAssets", false, "set to true to hide successful asset requests (js, css, images, fonts...) from the access log")
+	c.compress = fs.Bool("compress", true, "set to false to disable gzip and brotli compression, and serving of precompressed '.br' and '.gz' files")
+	c.mocksDir = fs.String("mocksDir", defaultMocksDir, "directory with mock api fixtures, such as '<mocksDir>/api/users/GET.json' for 'GET /api/users'. Relative to the served directory, set empty to disable")
+	c.basicAuth = fs.String("auth", "", "protect the server and websocket with http basic auth, formatted as 'user:pass'")
+	c.token = fs.String("token", "", "protect the server and websocket with an access token. Open a page with '?sws_token=<token>' once to sign in")
+	c.allowedOrigins = fs.String("allowedOrigins", "", "comma separated origins allowed to connect to the websocket, in addition to the same origin. '*' allows all")
+	c.poll = fs.Bool("poll", false, "set to true to detect file changes by polling instead of filesystem notifications, for network filesystems and container mounts. Used automatically if notifications fail")
+	c.pollInterval = fs.Duration("pollInterval", wsinject.DefaultPollInterval, "interval at which files are polled for changes")
+	c.pollHash = fs.Bool("pollHash", false, "set to true to also compare file contents when polling, for filesystems with coarse modification times")
+	c.templates = fs.Bool("templates", false, "set to true to render '*.tmpl.html' files with go html/template into '*.html' files, with the layouts in -layoutsDir and the json and yaml files in -dataDir")
+	c.layoutsDir = fs.String("layoutsDir", "_layouts", "directory with the layout templates, relative to the served directory")
+	c.dataDir = fs.String("dataDir", "_data", "directory with json and yaml data files passed to templates, relative to the served directory")
+	c.includes = fs.Bool("includes", true, "set to false to not resolve '<!--#include file=\"...\" -->' and '<sws-include src=\"...\">' directives in html files")
+	c.persistMirror = fs.Bool("persistentMirror", false, "set to true to keep the mirror in a per project directory in the user cache dir, so that restarts only rewrite changed files. By default a temporary mirror is used, which is removed on shutdown")
+	c.followSymlinks = fs.Bool("followSymlinks", false, "set to true to mirror and watch symlinked directories, also if they point outside of the served directory")
+	c.injectExts = fs.String("injectExtensions", strings.Join(wsinject.DefaultInjectExtensions, ","), "comma separated extensions of files to inject the live reload script into")
+	c.injectSniff = fs.Bool("injectSniff", true, "set to false to only inject the live reload script into files by extension or -injectInclude, not into files detected as html by content")
+	fs.Func("injectInclude", "glob of files to always inject the live reload script into, such as '*.tmpl'. Globs without '/' match file names, '**' matches across directories. Can be repeated", func(s string) error {
+		c.injectInclude = append(c.injectInclude, s)
+		return nil
+	})
+	fs.Func("injectExclude", "glob of files to never inject the live reload script into, such as 'coverage/**'. Takes precedence over all other injection rules. Can be repeated", func(s string) error {
+		c.injectExclude = append(c.injectExclude, s)
+		return nil
+	})
+	c.redirectsFile = fs.String("redirectsFile", "", "netlify style file with redirect and rewrite rules, defaults to '_redirects' in the served directory")
+	fs.Func("redirect", "redirect or rewrite rule: '<from> <to> [status][!]', such as '/blog/* /posts/:splat 301'. Can be repeated", func(s string) error {
+		c.redirectEntries = append(c.redirectEntries, s)
+		return nil
+	})
+	c.headersFile = fs.String("headersFile", "", "netlify style file with custom response headers per url pattern, defaults to '_headers' in the served directory")
+	fs.Func("header", "custom response header rule: '<pattern> <Name>: <value>', '<pattern> +<Name>: <value>' to append or '<pattern> ! <Name>' to remove. Can be repeated", func(s string) error {
+		c.headerEntries = append(c.headerEntries, s)
+		return nil
+	})
 	c.flagset = fs
 	return fs
 }
 
 func (c *command) Run(ctx context.Context) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// the mirror is cleaned up on early returns as well, Close only cleans up once
+	defer func() {
+		if c.fileserver != nil {
+			c.fileserver.Close()
+		}
+	}()
+	accessLog := &accessLogger{
+		format:     *c.accessLog,
+		hideAssets: *c.hideAssets,
+		stdout:     os.Stdout,
+	}
+	if ancli.Stdout != nil {
+		accessLog.stdout = ancli.Stdout
+	}
+	if *c.accessLogFile != "" {
+		f, err := os.OpenFile(*c.accessLogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open access log file: %v", err)
+		}
+		defer f.Close()
+		accessLog.file = f
+	}
+
 	mux := http.NewServeMux()
-	fsh := http.FileServer(http.Dir(c.mirrorPath))
-	fsh = slogHandler(fsh)
+	fsh := newEtagger(c.mirrorPath).handler(http.FileServer(http.Dir(c.mirrorPath)))
+	if c.mocks != nil {
+		fsh = c.mocks.handler(fsh)
+	}
+	fsh = c.redirectRules.handler(fsh)
+	if *c.compress {
+		fsh = compressionHandler(fsh, c.mirrorPath)
+	}
 	fsh = cacheHandler(fsh, *c.cacheControl)
+	fsh = c.headerRules.handler(fsh)
+	if c.auth != nil {
+		fsh = c.auth.handler(fsh)
+	}
+	fsh = accessLog.handler(fsh)
 	mux.Handle("/", fsh)
 
-	ancli.PrintfOK("setting up websocket host on path: '%v'", *c.wsPath)
-	mux.HandleFunc(*c.wsPath, func(w http.ResponseWriter, r *http.Request) {
-		upgrader := websocket.Upgrader{}
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			http.Error(w, "Failed to upgrade to websocket", http.StatusInternalServerError)
-			return
-		}
-		c.fileserver.WsHandler(conn)
-	})
+	ancli.OK("setting up websocket host", "path", *c.wsPath)
+	var wsh http.Handler = http.HandlerFunc(c.liveReload.ServeWebsocket)
+	if c.auth != nil {
+		wsh = c.auth.wsHandler(wsh)
+	}
+	mux.Handle(*c.wsPath, wsh)
 
 	s := http.Server{
 		Addr:        fmt.Sprintf(":%v", *c.port),
 		Handler:     mux,
 		ReadTimeout: 0,
 	}
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port: %v, err: %v", *c.port, err)
+	}
+
 	serverErrChan := make(chan error, 1)
 	fsErrChan := make(chan error, 1)
+	fsDone := make(chan struct{})
 	go func() {
-		ancli.PrintfOK("now serving directory: '%v' on port: '%v', mirror dir is: '%v'", c.masterPath, *c.port, c.mirrorPath)
-		err := s.ListenAndServe()
+		ancli.OK("now serving directory", "path", c.masterPath, "port", *c.port, "mirror", c.mirrorPath)
+		events.Emit(events.Event{
+			Type:   events.Ready,
+			URL:    fmt.Sprintf("http://localhost:%v/", *c.port),
+			Path:   c.masterPath,
+			Mirror: c.mirrorPath,
+		})
+		err := s.Serve(ln)
 		if !errors.Is(err, http.ErrServerClosed) {
 			serverErrChan <- err
 		}
 	}()
+	watcher := c.mocks.watcher(c.fileserver)
+	if *c.checkLinks {
+		var lcOpts []linkcheck.Option
+		if *c.includes {
+			lcOpts = append(lcOpts, linkcheck.WithIncludes())
+		}
+		if *c.templates {
+			lcOpts = append(lcOpts, linkcheck.WithTemplates(c.rootRelative(*c.layoutsDir), c.rootRelative(*c.dataDir)))
+		}
+		lc := newLinkChecker(c.masterPath, lcOpts...)
+		watcher = lc.watcher(watcher)
+		go lc.run(ctx)
+	}
 	go func() {
-		ancli.PrintOK("starting fsnotify file detector")
-		if err := c.fileserver.Start(ctx); err != nil {
+		defer close(fsDone)
+		ancli.Debug("starting fsnotify file detector")
+		if err := c.liveReload.Watch(ctx, watcher); err != nil {
 			fsErrChan <- err
 		}
 	}()
@@ -133,6 +379,15 @@ func (c *command) Run(ctx context.Context) (err error) {
 
 	ancli.PrintNotice("initiating webserver graceful shutdown")
 	s.Shutdown(ctx)
+	// the mirror is only cleaned up once no more file changes are mirrored into it
+	cancel()
+	<-fsDone
+	c.liveReload.Close()
+	if c.fileserver != nil {
+		if closeErr := c.fileserver.Close(); closeErr != nil {
+			ancli.Err("failed to clean up mirror", "err", closeErr)
+		}
+	}
 	ancli.PrintOK("shutdown complete")
 	return err
 }
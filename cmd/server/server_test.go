@@ -2,9 +2,12 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
 
@@ -17,12 +20,14 @@ func (m *mockFileServer) Setup(pathToMaster string) (string, error) {
 	return "/mock/mirror/path", nil
 }
 
-func (m *mockFileServer) Start(ctx context.Context) error {
+func (m *mockFileServer) Watch(ctx context.Context, notify func(urlPath string)) error {
 	<-ctx.Done()
 	return ctx.Err()
 }
 
-func (m *mockFileServer) WsHandler(ws *websocket.Conn) {}
+func (m *mockFileServer) Close() error {
+	return nil
+}
 
 func Test_Setup(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -105,7 +110,7 @@ func TestRun(t *testing.T) {
 
 		<-ready
 		// test if the HTTP server is working
-		resp, err := http.Get("http://localhost:8081/")
+		resp, err := awaitGet(t, "http://localhost:8081/")
 		if err != nil {
 			t.Fatalf("Failed to send GET request: %e", err)
 		}
@@ -116,21 +121,7 @@ func TestRun(t *testing.T) {
 		}
 
 		// test the websocket handler
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			upgrader := websocket.Upgrader{
-				CheckOrigin: func(r *http.Request) bool {
-					return true
-				},
-			}
-
-			// upgrade the HTTP connection to a WebSocket connection
-			ws, err := upgrader.Upgrade(w, r, nil)
-			if err != nil {
-				t.Errorf("Failed to upgrade connection: %e", err)
-				return
-			}
-			cmd.fileserver.WsHandler(ws)
-		}))
+		server := httptest.NewServer(http.HandlerFunc(cmd.liveReload.ServeWebsocket))
 
 		t.Cleanup(func() { server.Close() })
 
@@ -158,8 +149,7 @@ func TestRun(t *testing.T) {
 			}
 		}()
 		<-ready
-		time.Sleep(time.Millisecond)
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%v", port))
+		resp, err := awaitGet(t, fmt.Sprintf("http://localhost:%v", port))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -167,4 +157,37 @@ func TestRun(t *testing.T) {
 			t.Errorf("Cache-Control: expected %v, got %v", want, got)
 		}
 	})
+
+	t.Run("it should remove the mirror if the server fails to start", func(t *testing.T) {
+		cmd := setup()
+		ln, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		port := ln.Addr().(*net.TCPAddr).Port
+		cmd.port = &port
+
+		if err := cmd.Run(context.Background()); err == nil {
+			t.Fatal("expected error for port in use")
+		}
+
+		if _, err := os.Stat(cmd.mirrorPath); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected the mirror: '%v' to be removed, got: %v", cmd.mirrorPath, err)
+		}
+	})
+}
+
+// awaitGet retries the GET request until the server
+// has started listening or a second has passed.
+func awaitGet(t *testing.T, url string) (*http.Response, error) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp, err := http.Get(url)
+		if err == nil || time.Now().After(deadline) {
+			return resp, err
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
 }
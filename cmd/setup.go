@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"strings"
 	"text/tabwriter"
 
+	"github.com/pchchv/sws/cmd/check"
 	"github.com/pchchv/sws/cmd/server"
 	"github.com/pchchv/sws/cmd/version"
 )
@@ -20,14 +24,26 @@ On filechanges, the websocket will trigger a reload of the page.
 The 41 (formerly "40", before I got spooked by potential lawyers) is only
 to enable rust-repellant properties.
 
+Usage: sws [-log-level <debug|info|warn|error>] [-log-format <text|json>] [-output <text|jsonl>] <command> [flags]
+
 Commands:
 %v`
 
 var commands = map[string]Command{
+	"c|check":   check.Command(),
 	"s|serve":   server.Command(),
 	"v|version": version.Command(),
 }
 
+// RootFlags are the flags which are set before the command.
+type RootFlags struct {
+	LogLevel  slog.Level
+	LogFormat string
+	// Output is either 'text' or 'jsonl'. With 'jsonl', stdout is reserved for
+	// the event stream and the log is written to stderr.
+	Output string
+}
+
 func PrintUsage() {
 	fmt.Printf(usage, formatCommandDescriptions())
 }
@@ -63,6 +79,42 @@ func Parse(args []string) (Command, error) {
 	return nil, ArgNotFoundError(cmdCandidate)
 }
 
+// ParseRootFlags parses the root flags preceding the command, such as 'sws -log-level debug serve'.
+// It returns the root flags and args with the root flags removed.
+// Help flags are left in args, to be handled by Parse.
+func ParseRootFlags(args []string) (RootFlags, []string, error) {
+	rf := RootFlags{
+		LogLevel:  slog.LevelInfo,
+		LogFormat: "text",
+		Output:    "text",
+	}
+	if len(args) <= 1 {
+		return rf, args, nil
+	}
+
+	fs := flag.NewFlagSet("sws", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.TextVar(&rf.LogLevel, "log-level", slog.LevelInfo, "minimum level to log: debug, info, warn or error")
+	fs.StringVar(&rf.LogFormat, "log-format", "text", "format of the log: text or json")
+	fs.StringVar(&rf.Output, "output", "text", "set to jsonl to emit machine-readable events on stdout, the log is then written to stderr")
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return rf, args, nil
+		}
+		return rf, args, err
+	}
+
+	if rf.LogFormat != "text" && rf.LogFormat != "json" {
+		return rf, args, fmt.Errorf("invalid log format: '%v', expected text or json", rf.LogFormat)
+	}
+
+	if rf.Output != "text" && rf.Output != "jsonl" {
+		return rf, args, fmt.Errorf("invalid output: '%v', expected text or jsonl", rf.Output)
+	}
+
+	return rf, append([]string{args[0]}, fs.Args()...), nil
+}
+
 func formatCommandDescriptions() string {
 	var buf bytes.Buffer
 	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
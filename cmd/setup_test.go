@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"errors"
+	"log/slog"
+	"slices"
 	"strings"
 	"testing"
 
@@ -58,3 +60,39 @@ func TestFormatCommandDescriptions(t *testing.T) {
 		t.Errorf("Expected formatted command descriptions to contain testCmd, got '%s'", result)
 	}
 }
+
+func Test_ParseRootFlags(t *testing.T) {
+	t.Run("it should parse root flags and remove them from args", func(t *testing.T) {
+		rf, args, err := ParseRootFlags([]string{"/some/cli/path", "-log-level", "debug", "-log-format=json", "serve", "-port", "9090"})
+		if err != nil {
+			t.Fatalf("failed to parse root flags: %v", err)
+		}
+
+		if rf.LogLevel != slog.LevelDebug || rf.LogFormat != "json" {
+			t.Fatalf("unexpected root flags: %+v", rf)
+		}
+
+		want := []string{"/some/cli/path", "serve", "-port", "9090"}
+		if !slices.Equal(args, want) {
+			t.Fatalf("expected: %v, got: %v", want, args)
+		}
+	})
+
+	t.Run("it should leave help flags for Parse", func(t *testing.T) {
+		given := []string{"/some/cli/path", "-h"}
+		_, args, err := ParseRootFlags(given)
+		if err != nil {
+			t.Fatalf("failed to parse root flags: %v", err)
+		}
+
+		if _, err := Parse(args); !errors.Is(err, ErrHelpful) {
+			t.Fatalf("expected ErrHelpful, got: %v", err)
+		}
+	})
+
+	t.Run("it should return error on invalid log format", func(t *testing.T) {
+		if _, _, err := ParseRootFlags([]string{"/some/cli/path", "-log-format", "xml", "serve"}); err == nil {
+			t.Fatal("expected error on invalid log format")
+		}
+	})
+}
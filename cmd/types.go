@@ -31,5 +31,5 @@ type Command interface {
 type ArgNotFoundError string
 
 func (err ArgNotFoundError) Error() string {
-	return fmt.Sprintf("'%s' is not a valid argument\n", err.Error())
+	return fmt.Sprintf("'%s' is not a valid argument\n", string(err))
 }
@@ -3,8 +3,11 @@ module github.com/pchchv/sws
 go 1.24.0
 
 require (
+	github.com/andybalholm/brotli v1.2.6
 	github.com/fsnotify/fsnotify v1.8.0
 	github.com/gorilla/websocket v1.5.3
+	golang.org/x/net v0.38.0
+	golang.org/x/sys v0.31.0
 )
 
-require golang.org/x/sys v0.13.0 // indirect
+require gopkg.in/yaml.v3 v3.0.1
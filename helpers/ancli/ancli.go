@@ -1,6 +1,7 @@
 package ancli
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -12,10 +13,14 @@ import (
 
 var (
 	slogger       *slog.Logger
+	logLevel      = slog.LevelInfo
 	SlogIt        = false
 	useColor      = os.Getenv("NO_COLOR") != "true"
 	printWarnings = !truthy(os.Getenv("NO_WARNINGS"))
 	Newline       = false || strings.ToLower(os.Getenv("ANCLI_NEWLINE")) == "true"
+	// Stdout receives all non-error messages, os.Stdout is used if it is nil.
+	// Set it before calling SetupSlog.
+	Stdout io.Writer
 )
 
 type colorCode int
@@ -29,11 +34,26 @@ const (
 	CYAN
 )
 
-func SetupSlog() {
-	slogger = slog.New(&ansiprint.ANSIPrint{})
+// SetupSlog sets up structured logging of all messages on level and above.
+// Messages are printed as JSON lines if asJSON is set, otherwise as colored text.
+func SetupSlog(level slog.Level, asJSON bool) {
+	logLevel = level
+	slogger = slog.New(&ansiprint.ANSIPrint{
+		Level:   level,
+		JSON:    asJSON,
+		NoColor: !useColor,
+		Stdout:  Stdout,
+	})
 	SlogIt = true
 }
 
+func stdout() io.Writer {
+	if Stdout != nil {
+		return Stdout
+	}
+	return os.Stdout
+}
+
 func ColoredMessage(cc colorCode, msg string) string {
 	return fmt.Sprintf("\x1b[%dm%v\x1b[0m", cc, msg)
 }
@@ -46,8 +66,12 @@ func PrintfErr(msg string, a ...any) {
 	PrintErr(fmt.Sprintf(msg, a...))
 }
 
-func printStatus(out io.Writer, status, msg string, color colorCode) {
+func printStatus(out io.Writer, status, msg string, color colorCode, attrs ...any) {
 	rawStatus := status
+	if statusLevel(rawStatus) < logLevel {
+		return
+	}
+
 	if useColor {
 		status = ColoredMessage(color, status)
 	}
@@ -62,26 +86,71 @@ func printStatus(out io.Writer, status, msg string, color colorCode) {
 			SlogIt = false
 			PrintErr("you have to run ancli.SetupSlog in order to use slog printing, defaulting to normal print")
 		} else {
-			// always newline slog messages
-			fmsg := fmt.Sprintf("%v: %v\n", status, msg)
-			switch rawStatus {
-			case "ok", "notice":
-				slogger.Info(fmsg)
-			case "error":
-				slogger.Error(fmsg)
-			case "warning":
-				slogger.Warn(fmsg)
-			default:
-				slogger.Warn(fmt.Sprintf("failed to find status for: '%v', msg is: %v", status, fmsg))
-			}
+			slogger.Log(context.Background(), statusLevel(rawStatus), msg, attrs...)
 		}
 	} else {
-		fmt.Fprintf(out, "%v: %v%v", status, msg, newline)
+		fmt.Fprintf(out, "%v: %v%v%v", status, msg, formatAttrs(attrs), newline)
 	}
 }
 
+func statusLevel(status string) slog.Level {
+	switch status {
+	case "debug":
+		return slog.LevelDebug
+	case "error":
+		return slog.LevelError
+	case "warning":
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// formatAttrs formats slog style key-value pairs as ' key=value' for non-slog printing.
+func formatAttrs(attrs []any) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	r := slog.Record{}
+	r.Add(attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&sb, " %v=%v", a.Key, a.Value)
+		return true
+	})
+	return sb.String()
+}
+
+// Debug prints msg with the slog style key-value pairs in attrs on debug level.
+func Debug(msg string, attrs ...any) {
+	printStatus(stdout(), "debug", msg, MAGENTA, attrs...)
+}
+
+// OK prints msg with the slog style key-value pairs in attrs on info level.
+func OK(msg string, attrs ...any) {
+	printStatus(stdout(), "ok", msg, GREEN, attrs...)
+}
+
+// Notice prints msg with the slog style key-value pairs in attrs on info level.
+func Notice(msg string, attrs ...any) {
+	printStatus(stdout(), "notice", msg, CYAN, attrs...)
+}
+
+// Warn prints msg with the slog style key-value pairs in attrs on warning level.
+func Warn(msg string, attrs ...any) {
+	if printWarnings {
+		printStatus(stdout(), "warning", msg, YELLOW, attrs...)
+	}
+}
+
+// Err prints msg with the slog style key-value pairs in attrs on error level.
+func Err(msg string, attrs ...any) {
+	printStatus(os.Stderr, "error", msg, RED, attrs...)
+}
+
 func PrintNotice(msg string) {
-	printStatus(os.Stdout, "notice", msg, CYAN)
+	printStatus(stdout(), "notice", msg, CYAN)
 }
 
 func PrintfNotice(msg string, a ...any) {
@@ -89,7 +158,7 @@ func PrintfNotice(msg string, a ...any) {
 }
 
 func PrintOK(msg string) {
-	printStatus(os.Stdout, "ok", msg, GREEN)
+	printStatus(stdout(), "ok", msg, GREEN)
 }
 
 func PrintfOK(msg string, a ...any) {
@@ -102,7 +171,7 @@ func Okf(msg string, a ...any) {
 
 func PrintWarn(msg string) {
 	if printWarnings {
-		printStatus(os.Stdout, "warning", msg, YELLOW)
+		printStatus(stdout(), "warning", msg, YELLOW)
 	}
 }
 
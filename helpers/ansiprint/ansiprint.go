@@ -4,37 +4,220 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
-type ANSIPrint struct{}
+const (
+	red     = 31
+	green   = 32
+	yellow  = 33
+	magenta = 35
+	cyan    = 36
+	faint   = 2
+)
+
+// mu serializes writes of all handlers, so that lines are never interleaved.
+var mu sync.Mutex
+
+// ANSIPrint is a slog.Handler which prints records as colored lines of
+// key=value pairs, or as JSON objects if JSON is set.
+// Records on level error are written to Stderr, all other to Stdout.
+// The zero value logs everything on level info and above to os.Stdout and os.Stderr.
+type ANSIPrint struct {
+	// Level is the minimum level which is logged, defaults to slog.LevelInfo.
+	Level slog.Leveler
+	// JSON prints records as JSON lines instead of colored text.
+	JSON bool
+	// NoColor disables the ANSI color codes in text mode.
+	NoColor bool
+	Stdout  io.Writer
+	Stderr  io.Writer
 
-func (a *ANSIPrint) Enabled(context.Context, slog.Level) bool {
-	return true
+	// preformatted attributes, added with WithAttrs
+	attrs []slog.Attr
+	// open groups, added with WithGroup
+	groups []string
+	// attrGroups holds the group prefix each attribute in attrs was added under
+	attrGroups [][]string
+}
+
+func (a *ANSIPrint) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if a.Level != nil {
+		minLevel = a.Level.Level()
+	}
+	return level >= minLevel
 }
 
 func (a *ANSIPrint) Handle(ctx context.Context, r slog.Record) error {
 	var bf bytes.Buffer
-	if !r.Time.IsZero() {
-		fmt.Fprintf(&bf, "%v %v", r.Time.Format(time.RFC3339), r.Message)
+	if a.JSON {
+		a.writeJSON(&bf, r)
+	} else {
+		a.writeText(&bf, r)
 	}
 
-	switch r.Level {
-	case slog.LevelDebug, slog.LevelWarn, slog.LevelInfo:
-		fmt.Fprint(os.Stdout, bf.String())
-	case slog.LevelError:
-		fmt.Fprint(os.Stderr, bf.String())
+	out := a.Stdout
+	if out == nil {
+		out = os.Stdout
+	}
+	if r.Level >= slog.LevelError {
+		out = a.Stderr
+		if out == nil {
+			out = os.Stderr
+		}
 	}
 
-	return nil
+	mu.Lock()
+	defer mu.Unlock()
+	_, err := out.Write(bf.Bytes())
+	return err
 }
 
 func (a *ANSIPrint) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return a
+	if len(attrs) == 0 {
+		return a
+	}
+
+	c := a.clone()
+	for _, attr := range attrs {
+		c.attrs = append(c.attrs, attr)
+		c.attrGroups = append(c.attrGroups, a.groups)
+	}
+	return c
 }
 
 func (a *ANSIPrint) WithGroup(name string) slog.Handler {
-	return a
+	if name == "" {
+		return a
+	}
+
+	c := a.clone()
+	c.groups = append(c.groups[:len(c.groups):len(c.groups)], name)
+	return c
+}
+
+func (a *ANSIPrint) clone() *ANSIPrint {
+	c := *a
+	c.attrs = a.attrs[:len(a.attrs):len(a.attrs)]
+	c.attrGroups = a.attrGroups[:len(a.attrGroups):len(a.attrGroups)]
+	return &c
+}
+
+// collect returns the attributes of the handler and the record, flattened with
+// their group prefixes as dotted keys.
+func (a *ANSIPrint) collect(r slog.Record) (keys []string, values []slog.Value) {
+	var add func(prefix []string, attr slog.Attr)
+	add = func(prefix []string, attr slog.Attr) {
+		attr.Value = attr.Value.Resolve()
+		if attr.Equal(slog.Attr{}) {
+			return
+		}
+
+		if attr.Value.Kind() == slog.KindGroup {
+			groupPrefix := prefix
+			if attr.Key != "" {
+				groupPrefix = append(prefix[:len(prefix):len(prefix)], attr.Key)
+			}
+			for _, ga := range attr.Value.Group() {
+				add(groupPrefix, ga)
+			}
+			return
+		}
+
+		keys = append(keys, strings.Join(append(prefix[:len(prefix):len(prefix)], attr.Key), "."))
+		values = append(values, attr.Value)
+	}
+
+	for i, attr := range a.attrs {
+		add(a.attrGroups[i], attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		add(a.groups, attr)
+		return true
+	})
+	return keys, values
+}
+
+func (a *ANSIPrint) color(code int, s string) string {
+	if a.NoColor {
+		return s
+	}
+	return fmt.Sprintf("\x1b[%dm%v\x1b[0m", code, s)
+}
+
+func (a *ANSIPrint) writeText(bf *bytes.Buffer, r slog.Record) {
+	if !r.Time.IsZero() {
+		bf.WriteString(a.color(faint, r.Time.Format(time.RFC3339)))
+		bf.WriteByte(' ')
+	}
+
+	bf.WriteString(a.color(levelColor(r.Level), fmt.Sprintf("%-5s", r.Level.String())))
+	bf.WriteByte(' ')
+	bf.WriteString(strings.TrimRight(r.Message, "\n"))
+	keys, values := a.collect(r)
+	for i, key := range keys {
+		bf.WriteByte(' ')
+		bf.WriteString(a.color(cyan, key+"="))
+		bf.WriteString(quoteIfNeeded(textValue(values[i])))
+	}
+	bf.WriteByte('\n')
+}
+
+func (a *ANSIPrint) writeJSON(bf *bytes.Buffer, r slog.Record) {
+	// a JSON handler without groups is used to get the standard slog JSON
+	// encoding of values, groups are already flattened by collect
+	attrs := make([]slog.Attr, 0, r.NumAttrs()+len(a.attrs))
+	keys, values := a.collect(r)
+	for i, key := range keys {
+		attrs = append(attrs, slog.Attr{Key: key, Value: values[i]})
+	}
+
+	jr := slog.NewRecord(r.Time, r.Level, strings.TrimRight(r.Message, "\n"), r.PC)
+	jr.AddAttrs(attrs...)
+	slog.NewJSONHandler(bf, nil).Handle(context.Background(), jr)
+}
+
+func levelColor(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return red
+	case level >= slog.LevelWarn:
+		return yellow
+	case level >= slog.LevelInfo:
+		return green
+	default:
+		return magenta
+	}
+}
+
+func textValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindDuration:
+		return v.Duration().Round(time.Microsecond).String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339)
+	default:
+		return v.String()
+	}
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" {
+		return `""`
+	}
+
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
 }
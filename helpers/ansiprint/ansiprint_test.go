@@ -0,0 +1,66 @@
+package ansiprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_ANSIPrint(t *testing.T) {
+	setup := func(t *testing.T, level slog.Level, asJSON bool) (*bytes.Buffer, *bytes.Buffer, *slog.Logger) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		return &stdout, &stderr, slog.New(&ANSIPrint{
+			Level:   level,
+			JSON:    asJSON,
+			NoColor: true,
+			Stdout:  &stdout,
+			Stderr:  &stderr,
+		})
+	}
+
+	t.Run("it should filter records below level", func(t *testing.T) {
+		stdout, _, logger := setup(t, slog.LevelWarn, false)
+		logger.Info("hidden")
+		if stdout.Len() != 0 {
+			t.Fatalf("expected info record to be filtered, got: '%v'", stdout.String())
+		}
+
+		logger.Warn("shown")
+		if !strings.Contains(stdout.String(), "WARN  shown") {
+			t.Fatalf("expected warn record to be printed, got: '%v'", stdout.String())
+		}
+	})
+
+	t.Run("it should print attributes and groups as key=value pairs", func(t *testing.T) {
+		stdout, _, logger := setup(t, slog.LevelInfo, false)
+		logger.With("client", "127.0.0.1").WithGroup("req").Info("served", "path", "/index.html", "agent", "some agent")
+		want := `INFO  served client=127.0.0.1 req.path=/index.html req.agent="some agent"` + "\n"
+		if got := stdout.String(); !strings.HasSuffix(got, want) {
+			t.Fatalf("expected suffix: '%v', got: '%v'", want, got)
+		}
+	})
+
+	t.Run("it should print errors to stderr", func(t *testing.T) {
+		stdout, stderr, logger := setup(t, slog.LevelInfo, false)
+		logger.Error("oh no")
+		if stdout.Len() != 0 || !strings.Contains(stderr.String(), "oh no") {
+			t.Fatalf("expected error on stderr only, stdout: '%v', stderr: '%v'", stdout.String(), stderr.String())
+		}
+	})
+
+	t.Run("it should print json lines in json mode", func(t *testing.T) {
+		stdout, _, logger := setup(t, slog.LevelDebug, true)
+		logger.WithGroup("ws").Debug("registering", "name", "ws-1")
+		var got map[string]any
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal json line: '%v', err: %v", stdout.String(), err)
+		}
+
+		if got["msg"] != "registering" || got["level"] != "DEBUG" || got["ws.name"] != "ws-1" {
+			t.Fatalf("unexpected json record: %v", got)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+// Package events emits a machine-readable stream of significant sws events,
+// one JSON object per line, for tools which drive sws as a subprocess.
+//
+// The schema is stable: fields are only ever added, never renamed or removed.
+// Every event has the fields "v" (schema version), "type" and "time".
+// The other fields are set depending on the type, see Event.
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Version of the event schema.
+const Version = 1
+
+type Type string
+
+const (
+	// Ready is emitted once the server is listening. URL, Path and Mirror are set.
+	Ready Type = "ready"
+	// FileChanged is emitted when a file in the served root is written. Path and File are set.
+	FileChanged Type = "file_changed"
+	// Mirrored is emitted when a file has been copied to the mirror. Path, File and DurationMs are set.
+	Mirrored Type = "mirrored"
+	// Injected is emitted when the delta-streamer script has been injected into a html file. Path and File are set.
+	Injected Type = "injected"
+	// ClientConnected is emitted when a browser connects to the websocket. Client is set.
+	ClientConnected Type = "client_connected"
+	// ClientDisconnected is emitted when a browser disconnects from the websocket. Client is set.
+	ClientDisconnected Type = "client_disconnected"
+	// ReloadSent is emitted when a change has been sent to the connected browsers. Path and Clients are set.
+	ReloadSent Type = "reload_sent"
+	// Error is emitted on errors which do not stop sws. Error is set, Path and File if known.
+	Error Type = "error"
+	// BrokenLink is emitted for broken references found by link checks. Path, Line, Ref and Error are set.
+	BrokenLink Type = "broken_link"
+)
+
+type Event struct {
+	Version int       `json:"v"`
+	Type    Type      `json:"type"`
+	Time    time.Time `json:"time"`
+	// URL the server is reachable on.
+	URL string `json:"url,omitempty"`
+	// Path is the url path of a file, relative to the served root, for example '/index.html'.
+	// For Ready, it is the served root directory.
+	Path string `json:"path,omitempty"`
+	// File is the absolute path of the original file on disk.
+	File string `json:"file,omitempty"`
+	// Mirror is the directory of the mirrored content.
+	Mirror string `json:"mirror,omitempty"`
+	// Client is the remote address of a websocket client.
+	Client string `json:"client,omitempty"`
+	// Clients is the amount of browsers a change was sent to.
+	Clients    *int    `json:"clients,omitempty"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	// Line is the line of a broken reference in the file on Path.
+	Line int `json:"line,omitempty"`
+	// Ref is a broken reference as written.
+	Ref string `json:"ref,omitempty"`
+}
+
+var (
+	mu  sync.Mutex
+	out io.Writer
+)
+
+// Enable starts writing events to w. Events are dropped until Enable has been called.
+func Enable(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+}
+
+// Disable stops writing events.
+func Disable() {
+	Enable(nil)
+}
+
+// Enabled reports if events are written anywhere.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return out != nil
+}
+
+// Emit writes e as a JSON line, setting the version and the time if unset.
+func Emit(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return
+	}
+
+	e.Version = Version
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	out.Write(append(b, '\n'))
+}
+
+// Err emits an Error event for err, concerning the file on path, if any.
+func Err(err error, path, file string) {
+	Emit(Event{Type: Error, Error: err.Error(), Path: path, File: file})
+}
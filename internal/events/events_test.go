@@ -0,0 +1,60 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Emit(t *testing.T) {
+	setup := func(t *testing.T) *bytes.Buffer {
+		t.Helper()
+		var buf bytes.Buffer
+		Enable(&buf)
+		t.Cleanup(Disable)
+		return &buf
+	}
+
+	t.Run("it should write one json object per line", func(t *testing.T) {
+		buf := setup(t)
+		clients := 0
+		Emit(Event{Type: ClientConnected, Client: "127.0.0.1:1234"})
+		Emit(Event{Type: ReloadSent, Path: "/index.html", Clients: &clients})
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got: %v", lines)
+		}
+
+		var got map[string]any
+		if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+			t.Fatalf("failed to unmarshal event: %v", err)
+		}
+
+		if got["v"] != float64(Version) || got["type"] != "reload_sent" || got["clients"] != float64(0) || got["time"] == nil {
+			t.Fatalf("unexpected event: %v", got)
+		}
+
+		if _, ok := got["client"]; ok {
+			t.Fatalf("expected unset fields to be omitted, got: %v", got)
+		}
+	})
+
+	t.Run("it should emit errors", func(t *testing.T) {
+		buf := setup(t)
+		Err(errors.New("oops"), "/a.html", "/site/a.html")
+		if want := `"type":"error"`; !strings.Contains(buf.String(), want) || !strings.Contains(buf.String(), `"error":"oops"`) {
+			t.Fatalf("expected error event, got: %v", buf.String())
+		}
+	})
+
+	t.Run("it should drop events when disabled", func(t *testing.T) {
+		buf := setup(t)
+		Disable()
+		Emit(Event{Type: Ready})
+		if buf.Len() != 0 || Enabled() {
+			t.Fatalf("expected no events, got: %v", buf.String())
+		}
+	})
+}
@@ -0,0 +1,102 @@
+// Package includes resolves html include directives, such as
+//
+//	<!--#include file="partials/nav.html" -->
+//	<!--#include virtual="/partials/nav.html" -->
+//	<sws-include src="/partials/nav.html"></sws-include>
+//
+// Relative paths, and 'file' includes, are resolved from the directory of the including file,
+// absolute paths, and 'virtual' includes, from the root. Included files may include files themselves.
+package includes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxDepth limits how deeply includes may be nested.
+const maxDepth = 16
+
+var (
+	ErrCycle       = errors.New("include cycle")
+	ErrOutsideRoot = errors.New("include outside of root")
+)
+
+var directiveRe = regexp.MustCompile(
+	`<!--#include\s+(file|virtual)\s*=\s*"([^"]*)"\s*-->` +
+		`|<sws-include\s+src\s*=\s*"([^"]*)"\s*(?:/>|>\s*</sws-include>)`,
+)
+
+// Resolve replaces the include directives in b, the content of the file on filePath within root,
+// with the content of the included files. It returns the absolute paths of all files which were
+// included, directly or nested, so that the file can be resolved again if one of them changes.
+// Directives which fail to resolve are replaced with an html comment describing the error,
+// and the errors are returned joined, along with the otherwise resolved content.
+func Resolve(root, filePath string, b []byte) ([]byte, []string, error) {
+	r := resolver{root: filepath.Clean(root)}
+	out := r.resolve(filepath.Clean(filePath), b, []string{filepath.Clean(filePath)})
+	slices.Sort(r.deps)
+	return out, slices.Compact(r.deps), errors.Join(r.errs...)
+}
+
+type resolver struct {
+	root string
+	deps []string
+	errs []error
+}
+
+func (r *resolver) resolve(filePath string, b []byte, stack []string) []byte {
+	return directiveRe.ReplaceAllFunc(b, func(directive []byte) []byte {
+		m := directiveRe.FindSubmatch(directive)
+		kind, src := string(m[1]), string(m[2])
+		if kind == "" {
+			kind, src = "src", string(m[3])
+		}
+
+		included, err := r.include(filePath, kind, src, stack)
+		if err != nil {
+			err = fmt.Errorf("failed to include '%v' in '%v': %w", src, filePath, err)
+			r.errs = append(r.errs, err)
+			return []byte("<!-- sws: " + strings.ReplaceAll(err.Error(), "--", "- -") + " -->")
+		}
+		return included
+	})
+}
+
+func (r *resolver) include(filePath, kind, src string, stack []string) ([]byte, error) {
+	if src == "" {
+		return nil, errors.New("empty path")
+	}
+
+	var p string
+	if kind == "virtual" || (kind == "src" && strings.HasPrefix(src, "/")) {
+		p = filepath.Join(r.root, filepath.FromSlash(src))
+	} else {
+		p = filepath.Join(filepath.Dir(filePath), filepath.FromSlash(src))
+	}
+
+	if rel, err := filepath.Rel(r.root, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, ErrOutsideRoot
+	}
+
+	if slices.Contains(stack, p) {
+		return nil, ErrCycle
+	}
+
+	if len(stack) > maxDepth {
+		return nil, fmt.Errorf("includes nested deeper than %v", maxDepth)
+	}
+
+	// tracked before reading, so that a missing partial is resolved again once it is created
+	r.deps = append(r.deps, p)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return r.resolve(p, bytes.TrimSuffix(b, []byte("\n")), append(stack, p)), nil
+}
@@ -0,0 +1,101 @@
+package includes
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_Resolve(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"partials/nav.html":    `<nav><!--#include file="links.html" --></nav>`,
+		"partials/links.html":  "<a href=\"/\">Home</a>\n",
+		"partials/footer.html": "<footer>Footer</footer>",
+		"partials/a.html":      `<!--#include file="b.html" -->`,
+		"partials/b.html":      `<!--#include file="a.html" -->`,
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, []byte(content), 0o644)
+	}
+	page := filepath.Join(root, "blog", "index.html")
+	partial := func(name string) string {
+		return filepath.Join(root, "partials", name)
+	}
+
+	tests := []struct {
+		name     string
+		html     string
+		want     string
+		wantDeps []string
+		wantErr  error
+	}{
+		{
+			name:     "it should resolve ssi virtual includes from the root",
+			html:     `<body><!--#include virtual="/partials/footer.html" --></body>`,
+			want:     `<body><footer>Footer</footer></body>`,
+			wantDeps: []string{partial("footer.html")},
+		},
+		{
+			name:     "it should resolve ssi file includes relative to the file",
+			html:     `<!--#include file="../partials/footer.html"-->`,
+			want:     `<footer>Footer</footer>`,
+			wantDeps: []string{partial("footer.html")},
+		},
+		{
+			name:     "it should resolve sws-include elements",
+			html:     `<sws-include src="/partials/footer.html"></sws-include><sws-include src="../partials/footer.html" />`,
+			want:     `<footer>Footer</footer><footer>Footer</footer>`,
+			wantDeps: []string{partial("footer.html")},
+		},
+		{
+			name:     "it should resolve nested includes",
+			html:     `<sws-include src="/partials/nav.html"></sws-include>`,
+			want:     `<nav><a href="/">Home</a></nav>`,
+			wantDeps: []string{partial("links.html"), partial("nav.html")},
+		},
+		{
+			name:     "it should track missing includes",
+			html:     `<sws-include src="/partials/missing.html"></sws-include>`,
+			wantDeps: []string{partial("missing.html")},
+			wantErr:  os.ErrNotExist,
+		},
+		{
+			name:     "it should detect cycles",
+			html:     `<!--#include virtual="/partials/a.html" -->`,
+			wantDeps: []string{partial("a.html"), partial("b.html")},
+			wantErr:  ErrCycle,
+		},
+		{
+			name:    "it should not include files outside of the root",
+			html:    `<!--#include file="../../secret.txt" -->`,
+			wantErr: ErrOutsideRoot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, deps, err := Resolve(root, page, []byte(tt.html))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr != nil {
+				if !strings.Contains(string(got), "<!-- sws: failed to include") {
+					t.Fatalf("expected error comment, got: %v", string(got))
+				}
+			} else if string(got) != tt.want {
+				t.Fatalf("expected: %q, got: %q", tt.want, string(got))
+			}
+
+			if !slices.Equal(deps, tt.wantDeps) {
+				t.Fatalf("expected deps: %v, got: %v", tt.wantDeps, deps)
+			}
+		})
+	}
+}
@@ -0,0 +1,461 @@
+// Package linkcheck finds broken references in a static site: links, images, scripts,
+// stylesheets and css urls which point to missing files or missing anchors, or which
+// only resolve on case insensitive filesystems.
+package linkcheck
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pchchv/sws/internal/events"
+	"github.com/pchchv/sws/internal/includes"
+	"golang.org/x/net/html"
+)
+
+type Kind string
+
+const (
+	// Missing is a reference to a file which does not exist.
+	Missing Kind = "missing"
+	// Anchor is a reference to a fragment, which no element of the page has as id.
+	Anchor Kind = "anchor"
+	// Case is a reference which differs in case from the file it resolves to, so it
+	// breaks once hosted on a case sensitive filesystem.
+	Case Kind = "case"
+)
+
+// Issue is a broken reference.
+type Issue struct {
+	// Path is the url path of the file with the reference, such as '/blog/index.html'.
+	Path string
+	// Line is the line of the reference, starting at 1.
+	Line int
+	// Ref is the reference as written.
+	Ref  string
+	Kind Kind
+	// Msg describes the problem.
+	Msg string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%v:%v: %v: '%v', %v", i.Path, i.Line, i.Kind, i.Ref, i.Msg)
+}
+
+// Emit emits the BrokenLink event of the issue, found in file.
+func (i Issue) Emit(file string) {
+	events.Emit(events.Event{
+		Type:  events.BrokenLink,
+		Path:  i.Path,
+		File:  file,
+		Line:  i.Line,
+		Ref:   i.Ref,
+		Error: fmt.Sprintf("%v: %v", i.Kind, i.Msg),
+	})
+}
+
+// refAttrs are the attributes holding references, by element.
+var refAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"embed":  {"src"},
+	"video":  {"src"},
+	"audio":  {"src"},
+	"track":  {"src"},
+	"input":  {"src"},
+}
+
+// templateSuffix marks templates, which are served as the '.html' page they render to.
+const templateSuffix = ".tmpl.html"
+
+var cssUrlRe = regexp.MustCompile(`url\(\s*(?:'([^']*)'|"([^"]*)"|([^'"\s)][^)\s]*))\s*\)`)
+
+// ref is a reference found in a file.
+type ref struct {
+	value string
+	line  int
+}
+
+// entry is a file or directory, as it is served.
+type entry struct {
+	name  string
+	isDir bool
+	// rendered is set for the pages which templates render to
+	rendered bool
+}
+
+type checker struct {
+	root      string
+	templates bool
+	includes  bool
+	// skip are the directories which are not checked, besides the skipped names
+	skip []string
+	// dirs caches the entries of directories, by url path
+	dirs map[string][]entry
+	// ids caches the ids of html files, by url path
+	ids map[string]map[string]bool
+}
+
+type Option func(*checker)
+
+// WithTemplates resolves references to the '.html' pages which '*.tmpl.html' templates render to,
+// and does not check layoutsDir and dataDir. Anchors of rendered pages are not checked, as their
+// elements may be defined by layouts.
+func WithTemplates(layoutsDir, dataDir string) Option {
+	return func(c *checker) {
+		c.templates = true
+		c.skip = append(c.skip, filepath.Clean(layoutsDir), filepath.Clean(dataDir))
+	}
+}
+
+// WithIncludes resolves include directives to find the ids of pages, so that anchors to elements of
+// included files resolve. References in included files are checked relative to the included file.
+func WithIncludes() Option {
+	return func(c *checker) {
+		c.includes = true
+	}
+}
+
+// Check checks the html and css files below root and returns the broken references,
+// ordered by file and line. Files in directories starting with '.' or '_', such as
+// layouts and data, and in node_modules are not checked, but may be referenced.
+func Check(root string, opts ...Option) ([]Issue, error) {
+	c := &checker{
+		root: root,
+		dirs: make(map[string][]entry),
+		ids:  make(map[string]map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var issues []Issue
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p != root && (skipDir(d.Name()) || slices.Contains(c.skip, filepath.Clean(p))) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		urlPath := "/" + filepath.ToSlash(rel)
+		fileIssues, err := c.checkFile(urlPath, p)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, fileIssues...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check: '%v', err: %v", root, err)
+	}
+	return issues, nil
+}
+
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "node_modules"
+}
+
+func isHTML(p string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	return ext == ".html" || ext == ".htm"
+}
+
+// checkFile checks the references of the file on p, served on urlPath.
+func (c *checker) checkFile(urlPath, p string) ([]Issue, error) {
+	var refs []ref
+	switch {
+	case isHTML(urlPath):
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var ids map[string]bool
+		refs, ids, err = parseHTML(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse: '%v', err: %v", p, err)
+		}
+		c.ids[urlPath] = c.withIncludedIds(p, b, ids)
+	case strings.EqualFold(path.Ext(urlPath), ".css"):
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		refs = cssRefs(string(b), 1)
+	default:
+		return nil, nil
+	}
+
+	var issues []Issue
+	for _, r := range refs {
+		if issue, ok := c.checkRef(urlPath, r.value); !ok {
+			issue.Path = urlPath
+			issue.Line = r.line
+			issue.Ref = r.value
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// checkRef checks the reference s of the file on urlPath. It returns the issue if it is broken.
+func (c *checker) checkRef(urlPath, s string) (Issue, bool) {
+	s = strings.TrimSpace(s)
+	// references to generated content can not be checked
+	if s == "" || strings.Contains(s, "{{") {
+		return Issue{}, true
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return Issue{Kind: Missing, Msg: fmt.Sprintf("invalid url: %v", err)}, false
+	}
+
+	if u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return Issue{}, true
+	}
+
+	target := urlPath
+	if u.Path != "" {
+		target = u.Path
+		if !strings.HasPrefix(target, "/") {
+			target = path.Join(path.Dir(urlPath), target)
+		}
+		target = path.Clean(target)
+
+		actual, isDir, found := c.lookup(target)
+		if !found || (!isDir && strings.HasSuffix(u.Path, "/")) {
+			return Issue{Kind: Missing, Msg: fmt.Sprintf("no file at: '%v'", target)}, false
+		}
+
+		if isDir {
+			// directories are served by their index
+			index := path.Join(target, "index.html")
+			if actual, _, found = c.lookup(index); !found {
+				return Issue{Kind: Missing, Msg: fmt.Sprintf("no index.html in directory: '%v'", target)}, false
+			}
+			target = index
+		}
+
+		if actual != target {
+			return Issue{Kind: Case, Msg: fmt.Sprintf("differs in case from: '%v', which breaks on case sensitive filesystems", actual)}, false
+		}
+	}
+
+	// 'top' scrolls to the top of any page
+	if u.Fragment == "" || u.Fragment == "top" || !isHTML(target) || c.isRendered(target) {
+		return Issue{}, true
+	}
+
+	ids, err := c.pageIds(target)
+	if err != nil {
+		return Issue{Kind: Anchor, Msg: fmt.Sprintf("failed to read: '%v', err: %v", target, err)}, false
+	}
+
+	if !ids[u.Fragment] {
+		return Issue{Kind: Anchor, Msg: fmt.Sprintf("no element with id: '%v' in: '%v'", u.Fragment, target)}, false
+	}
+	return Issue{}, true
+}
+
+// lookup resolves the url path p by the exact names of the files, or else by names which
+// only differ in case. It returns the url path of the file p resolves to.
+func (c *checker) lookup(p string) (actual string, isDir, found bool) {
+	actual = "/"
+	isDir = true
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+
+		if !isDir {
+			return "", false, false
+		}
+
+		entries := c.readDir(actual)
+		i := slices.IndexFunc(entries, func(e entry) bool { return e.name == name })
+		if i == -1 {
+			i = slices.IndexFunc(entries, func(e entry) bool { return strings.EqualFold(e.name, name) })
+		}
+		if i == -1 {
+			return "", false, false
+		}
+
+		actual = path.Join(actual, entries[i].name)
+		isDir = entries[i].isDir
+	}
+	return actual, isDir, true
+}
+
+// readDir returns the entries of the directory on the url path dir, including the pages which
+// templates render to.
+func (c *checker) readDir(dir string) []entry {
+	if entries, ok := c.dirs[dir]; ok {
+		return entries
+	}
+
+	var entries, rendered []entry
+	dirEntries, _ := os.ReadDir(filepath.Join(c.root, filepath.FromSlash(dir)))
+	for _, e := range dirEntries {
+		isDir := e.IsDir()
+		if e.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(filepath.Join(c.root, filepath.FromSlash(dir), e.Name()))
+			if err != nil {
+				continue
+			}
+			isDir = info.IsDir()
+		}
+		entries = append(entries, entry{name: e.Name(), isDir: isDir})
+
+		if c.templates && !isDir && strings.HasSuffix(e.Name(), templateSuffix) {
+			name := strings.TrimSuffix(e.Name(), templateSuffix) + ".html"
+			rendered = append(rendered, entry{name: name, rendered: true})
+		}
+	}
+	// files take precedence over rendered pages of the same name
+	entries = append(entries, rendered...)
+	c.dirs[dir] = entries
+	return entries
+}
+
+// isRendered reports if the url path p is a template, or a page which a template renders to.
+func (c *checker) isRendered(p string) bool {
+	if !c.templates {
+		return false
+	}
+	if strings.HasSuffix(p, templateSuffix) {
+		return true
+	}
+
+	i := slices.IndexFunc(c.readDir(path.Dir(p)), func(e entry) bool { return e.name == path.Base(p) })
+	return i != -1 && c.dirs[path.Dir(p)][i].rendered
+}
+
+// withIncludedIds adds the ids of the files which the html document b on p includes to ids.
+func (c *checker) withIncludedIds(p string, b []byte, ids map[string]bool) map[string]bool {
+	if !c.includes {
+		return ids
+	}
+
+	// failed includes are reported when mirroring, the ids of the resolved ones still count
+	resolved, deps, _ := includes.Resolve(c.root, p, b)
+	if len(deps) == 0 {
+		return ids
+	}
+	if _, resolvedIds, err := parseHTML(resolved); err == nil {
+		maps.Copy(ids, resolvedIds)
+	}
+	return ids
+}
+
+// pageIds returns the ids of the html file on urlPath, which may be one that is not checked.
+func (c *checker) pageIds(urlPath string) (map[string]bool, error) {
+	if ids, ok := c.ids[urlPath]; ok {
+		return ids, nil
+	}
+
+	p := filepath.Join(c.root, filepath.FromSlash(urlPath))
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, ids, err := parseHTML(b)
+	if err != nil {
+		return nil, err
+	}
+	c.ids[urlPath] = c.withIncludedIds(p, b, ids)
+	return ids, nil
+}
+
+// parseHTML returns the references of the html document b and the ids of its elements,
+// which include the names of anchors.
+func parseHTML(b []byte) ([]ref, map[string]bool, error) {
+	var refs []ref
+	ids := make(map[string]bool)
+	z := html.NewTokenizer(bytes.NewReader(b))
+	line := 1
+	inStyle := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if !errors.Is(z.Err(), io.EOF) {
+				return nil, nil, z.Err()
+			}
+			return refs, ids, nil
+		}
+
+		tokenLine := line
+		line += bytes.Count(z.Raw(), []byte("\n"))
+		switch tt {
+		case html.TextToken:
+			if inStyle {
+				refs = append(refs, cssRefs(string(z.Text()), tokenLine)...)
+			}
+		case html.EndTagToken:
+			inStyle = false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			inStyle = tag == "style" && tt == html.StartTagToken
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch attr := string(key); {
+				case attr == "id" || (attr == "name" && tag == "a"):
+					ids[string(val)] = true
+				case attr == "style":
+					refs = append(refs, cssRefs(string(val), tokenLine)...)
+				case attr == "srcset" && slices.Contains(refAttrs[tag], attr):
+					for _, candidate := range strings.Split(string(val), ",") {
+						if fields := strings.Fields(candidate); len(fields) > 0 {
+							refs = append(refs, ref{value: fields[0], line: tokenLine})
+						}
+					}
+				case slices.Contains(refAttrs[tag], attr):
+					refs = append(refs, ref{value: string(val), line: tokenLine})
+				}
+			}
+		}
+	}
+}
+
+// cssRefs returns the url() references of the css s, which starts on line.
+func cssRefs(s string, line int) []ref {
+	var refs []ref
+	offset := 0
+	for _, m := range cssUrlRe.FindAllStringSubmatchIndex(s, -1) {
+		line += strings.Count(s[offset:m[0]], "\n")
+		offset = m[0]
+		for g := 2; g < len(m); g += 2 {
+			if m[g] != -1 {
+				refs = append(refs, ref{value: s[m[g]:m[g+1]], line: line})
+				break
+			}
+		}
+	}
+	return refs
+}
@@ -0,0 +1,174 @@
+package linkcheck
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write: '%v', err: %v", p, err)
+		}
+	}
+	return root
+}
+
+func Test_Check(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// opts returns the options of the check of root
+		opts func(root string) []Option
+		want []Issue
+	}{
+		{
+			name: "it should accept references to existing files and anchors",
+			files: map[string]string{
+				"index.html": `<a href="/blog/">blog</a>
+<a href="about.html#team">team</a> <a href="#top">top</a> <a href="#main">main</a>
+<img src="img/logo.png" srcset="img/logo.png 1x, img/logo.png?v=2 2x">
+<a href="https://example.com/missing">external</a> <a href="mailto:a@b.c">mail</a>
+<img src="data:image/png;base64,AAAA"> <main id="main"></main>`,
+				"about.html":      `<section id="team"></section>`,
+				"blog/index.html": `<a href="../index.html">home</a><link rel="stylesheet" href="/css/site.css">`,
+				"css/site.css":    `body { background: url("../img/logo.png"); }`,
+				"img/logo.png":    "png",
+			},
+		},
+		{
+			name: "it should report missing files with their lines",
+			files: map[string]string{
+				"index.html": `<html>
+<a href="missing.html">missing</a>
+<img srcset="ok.png 1x,
+  gone.png 2x">
+<style>
+  body { background: url(bg.png); }
+</style>
+<div style="background-image: url('/nope.png')"></div>`,
+				"ok.png":       "png",
+				"css/site.css": "\n\na { background: url(/icons/a.svg) }",
+			},
+			want: []Issue{
+				{Path: "/css/site.css", Line: 3, Ref: "/icons/a.svg", Kind: Missing, Msg: "no file at: '/icons/a.svg'"},
+				{Path: "/index.html", Line: 2, Ref: "missing.html", Kind: Missing, Msg: "no file at: '/missing.html'"},
+				{Path: "/index.html", Line: 3, Ref: "gone.png", Kind: Missing, Msg: "no file at: '/gone.png'"},
+				{Path: "/index.html", Line: 6, Ref: "bg.png", Kind: Missing, Msg: "no file at: '/bg.png'"},
+				{Path: "/index.html", Line: 8, Ref: "/nope.png", Kind: Missing, Msg: "no file at: '/nope.png'"},
+			},
+		},
+		{
+			name: "it should report missing anchors",
+			files: map[string]string{
+				"index.html": `<a href="#nowhere">a</a><a href="other.html#named">b</a><a href="other.html#gone">c</a>`,
+				"other.html": `<a name="named"></a>`,
+			},
+			want: []Issue{
+				{Path: "/index.html", Line: 1, Ref: "#nowhere", Kind: Anchor, Msg: "no element with id: 'nowhere' in: '/index.html'"},
+				{Path: "/index.html", Line: 1, Ref: "other.html#gone", Kind: Anchor, Msg: "no element with id: 'gone' in: '/other.html'"},
+			},
+		},
+		{
+			name: "it should report references differing in case",
+			files: map[string]string{
+				"index.html":      `<img src="Img/Logo.png"><a href="docs/">docs</a>`,
+				"img/logo.png":    "png",
+				"docs/Index.html": "docs",
+			},
+			want: []Issue{
+				{Path: "/index.html", Line: 1, Ref: "Img/Logo.png", Kind: Case, Msg: "differs in case from: '/img/logo.png', which breaks on case sensitive filesystems"},
+				{Path: "/index.html", Line: 1, Ref: "docs/", Kind: Case, Msg: "differs in case from: '/docs/Index.html', which breaks on case sensitive filesystems"},
+			},
+		},
+		{
+			name: "it should report directories without index and files linked as directories",
+			files: map[string]string{
+				"index.html":  `<a href="/empty/">a</a><a href="/page.html/">b</a>`,
+				"empty/.keep": "",
+				"page.html":   "",
+			},
+			want: []Issue{
+				{Path: "/index.html", Line: 1, Ref: "/empty/", Kind: Missing, Msg: "no index.html in directory: '/empty'"},
+				{Path: "/index.html", Line: 1, Ref: "/page.html/", Kind: Missing, Msg: "no file at: '/page.html'"},
+			},
+		},
+		{
+			name: "it should not check skipped directories and template expressions",
+			files: map[string]string{
+				"index.html":            `<a href="{{ .URL }}">a</a>`,
+				"_layouts/base.html":    `<a href="missing.html">a</a>`,
+				"node_modules/x.html":   `<a href="missing.html">a</a>`,
+				".git/description.html": `<a href="missing.html">a</a>`,
+			},
+		},
+		{
+			name: "it should resolve the pages which templates render to",
+			files: map[string]string{
+				"index.html":           `<a href="/about.html#team">about</a><a href="blog/">blog</a><a href="gone.html">gone</a>`,
+				"about.tmpl.html":      `{{template "base.html" .}}`,
+				"blog/index.tmpl.html": `<a href="#top">top</a><a href="#posts">posts</a>`,
+				"layouts/base.html":    `<a href="missing.html">a</a>`,
+			},
+			opts: func(root string) []Option {
+				return []Option{WithTemplates(filepath.Join(root, "layouts"), filepath.Join(root, "data"))}
+			},
+			want: []Issue{
+				{Path: "/index.html", Line: 1, Ref: "gone.html", Kind: Missing, Msg: "no file at: '/gone.html'"},
+			},
+		},
+		{
+			name: "it should report pages which templates render to without templates",
+			files: map[string]string{
+				"index.html":      `<a href="/about.html">about</a>`,
+				"about.tmpl.html": `about`,
+			},
+			want: []Issue{
+				{Path: "/index.html", Line: 1, Ref: "/about.html", Kind: Missing, Msg: "no file at: '/about.html'"},
+			},
+		},
+		{
+			name: "it should resolve anchors to included elements",
+			files: map[string]string{
+				"index.html":        "<!--#include virtual=\"/partials/nav.html\" -->\n<a href=\"#nav\">nav</a>",
+				"about.html":        `<a href="index.html#nav">nav</a>`,
+				"partials/nav.html": `<nav id="nav"></nav>`,
+			},
+			opts: func(string) []Option { return []Option{WithIncludes()} },
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			root := writeFiles(t, tc.files)
+			var opts []Option
+			if tc.opts != nil {
+				opts = tc.opts(root)
+			}
+			got, err := Check(root, opts...)
+			if err != nil {
+				t.Fatalf("failed to check: %v", err)
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_Issue(t *testing.T) {
+	t.Run("it should format the issue with its file and line", func(t *testing.T) {
+		got := Issue{Path: "/index.html", Line: 3, Ref: "a.png", Kind: Missing, Msg: "no file at: '/a.png'"}.String()
+		want := "/index.html:3: missing: 'a.png', no file at: '/a.png'"
+		if got != want {
+			t.Fatalf("expected: %v, got: %v", want, got)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package wsinject
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sniffLen is the amount of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// placement is how a file was placed into the mirror.
+type placement string
+
+const (
+	placeUnchanged placement = "unchanged"
+	placeInjected  placement = "injected"
+	placeWritten   placement = "written"
+	placeLinked    placement = "linked"
+	placeReflinked placement = "reflinked"
+	placeCopied    placement = "copied"
+)
+
+// mirrorStats counts how files were placed into the mirror.
+type mirrorStats struct {
+	files  int
+	bytes  int64
+	placed map[placement]int
+}
+
+func (s *mirrorStats) add(p placement, size int64) {
+	if s.placed == nil {
+		s.placed = map[placement]int{}
+	}
+	s.files++
+	s.bytes += size
+	s.placed[p]++
+}
+
+// readHead reads up to sniffLen bytes of f, for content sniffing.
+func readHead(f *os.File) ([]byte, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// linkOrCopy places src on dst without reading it into memory, by hard link, by reflink where
+// the filesystem supports it, or else by a streaming copy. Hard links and reflinks fail across
+// devices, such as when the mirror is on a tmpfs.
+func linkOrCopy(src, dst string) (placement, error) {
+	// link the file itself, not a symlink to it
+	src, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Link(src, dst); err == nil {
+		return placeLinked, nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	placed := placeReflinked
+	if err := reflink(src, dst); err != nil {
+		placed = placeCopied
+		if err := copyFile(src, dst); err != nil {
+			return "", err
+		}
+	}
+	return placed, preserveAttributes(dst, info)
+}
+
+// preserveAttributes sets the permissions and modification time of p to those of the original,
+// so that the mirror is served with the same Last-Modified headers as the original would be.
+func preserveAttributes(p string, original os.FileInfo) error {
+	if err := os.Chmod(p, original.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to preserve mode: %v", err)
+	}
+
+	if err := os.Chtimes(p, time.Time{}, original.ModTime()); err != nil {
+		return fmt.Errorf("failed to preserve modification time: %v", err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	info, err := s.Stat()
+	if err != nil {
+		return err
+	}
+
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(d, s); err != nil {
+		d.Close()
+		return fmt.Errorf("failed to copy: %v", err)
+	}
+	return d.Close()
+}
@@ -0,0 +1,126 @@
+package wsinject
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_linkOrCopy(t *testing.T) {
+	t.Run("it should place the file content", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "video.mp4"), filepath.Join(dir, "mirror.mp4")
+		want := bytes.Repeat([]byte{0, 1, 2, 3}, 1<<16)
+		os.WriteFile(src, want, 0o644)
+		if _, err := linkOrCopy(src, dst); err != nil {
+			t.Fatalf("failed to place file: %v", err)
+		}
+
+		if got, _ := os.ReadFile(dst); !bytes.Equal(got, want) {
+			t.Fatal("expected placed file to equal source")
+		}
+	})
+
+	t.Run("it should place the target of symlinks", func(t *testing.T) {
+		dir := t.TempDir()
+		target, src, dst := filepath.Join(dir, "target.js"), filepath.Join(dir, "link.js"), filepath.Join(dir, "mirror.js")
+		os.WriteFile(target, []byte("target"), 0o644)
+		if err := os.Symlink("target.js", src); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+
+		if _, err := linkOrCopy(src, dst); err != nil {
+			t.Fatalf("failed to place file: %v", err)
+		}
+
+		info, err := os.Lstat(dst)
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			t.Fatalf("expected placed file to be a regular file, got: %v, err: %v", info, err)
+		}
+	})
+}
+
+func Test_copyFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "data.bin"), filepath.Join(dir, "copy.bin")
+	want := bytes.Repeat([]byte("data"), 1<<16)
+	os.WriteFile(src, want, 0o600)
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, want) {
+		t.Fatal("expected copy to equal source")
+	}
+}
+
+func Test_mirrorFile(t *testing.T) {
+	root := t.TempDir()
+	fs := newTestFileServer(t)
+	if _, err := fs.Setup(root); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+
+	t.Run("it should not write through to the original file", func(t *testing.T) {
+		orig := filepath.Join(root, "page")
+		os.WriteFile(orig, []byte("plain text first"), 0o644)
+		if _, err := fs.mirrorFile(orig); err != nil {
+			t.Fatalf("failed to mirror: %v", err)
+		}
+
+		// the file is now sniffed as html, so it gets injected into
+		os.WriteFile(orig, []byte(mockHtml), 0o644)
+		if _, err := fs.mirrorFile(orig); err != nil {
+			t.Fatalf("failed to mirror: %v", err)
+		}
+
+		if b, _ := os.ReadFile(orig); strings.Contains(string(b), "delta-streamer.js") {
+			t.Fatal("expected original file to be unchanged")
+		}
+
+		if b, _ := os.ReadFile(filepath.Join(fs.mirrorPath, "page")); !strings.Contains(string(b), "delta-streamer.js") {
+			t.Fatal("expected mirrored file to be injected")
+		}
+	})
+}
+
+func Test_preserveAttributes(t *testing.T) {
+	root := t.TempDir()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := map[string]os.FileMode{
+		"index.html": 0o640,
+		"run.sh":     0o750,
+	}
+	for name, mode := range files {
+		p := filepath.Join(root, name)
+		os.WriteFile(p, []byte(mockHtml), mode)
+		os.Chmod(p, mode)
+		os.Chtimes(p, modTime, modTime)
+	}
+
+	fs := newTestFileServer(t)
+	if _, err := fs.Setup(root); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+
+	for name, mode := range files {
+		t.Run("it should preserve mode and modification time of "+name, func(t *testing.T) {
+			info, err := os.Stat(filepath.Join(fs.mirrorPath, name))
+			if err != nil {
+				t.Fatalf("failed to stat mirrored file: %v", err)
+			}
+
+			if info.Mode().Perm() != mode {
+				t.Fatalf("expected mode: %v, got: %v", mode, info.Mode().Perm())
+			}
+
+			if !info.ModTime().Equal(modTime) {
+				t.Fatalf("expected modification time: %v, got: %v", modTime, info.ModTime())
+			}
+		})
+	}
+}
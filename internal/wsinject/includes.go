@@ -0,0 +1,127 @@
+package wsinject
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/internal/events"
+)
+
+// WithIncludes enables or disables resolving include directives in html files,
+// see the includes package. Enabled by default.
+func WithIncludes(enabled bool) Option {
+	return func(fs *Fileserver) {
+		fs.includes = enabled
+	}
+}
+
+// hashDeps returns the content hashes of the files, by path.
+func hashDeps(deps []string) map[string]string {
+	if len(deps) == 0 {
+		return nil
+	}
+
+	hashes := make(map[string]string, len(deps))
+	for _, dep := range deps {
+		// missing files are tracked with an empty hash, so that creating them updates the file
+		hashes[dep], _ = hashContent(dep)
+	}
+	return hashes
+}
+
+// mirrorModTime returns the modification time of the mirror of the file with info, which
+// is the newest one of the file and the files it depends on, as the mirror changes with them.
+func mirrorModTime(info os.FileInfo, deps []string) time.Time {
+	modTime := info.ModTime()
+	for _, dep := range deps {
+		if depInfo, err := os.Stat(dep); err == nil && depInfo.ModTime().After(modTime) {
+			modTime = depInfo.ModTime()
+		}
+	}
+	return modTime
+}
+
+// setMirrorModTime sets the modification time of the mirror on p of the file with info, if it depends
+// on other files. Otherwise servers caching by modification time serve it as unchanged after they change.
+func setMirrorModTime(p string, info os.FileInfo, deps []string) error {
+	modTime := mirrorModTime(info, deps)
+	if modTime.Equal(info.ModTime()) {
+		return nil
+	}
+
+	if err := os.Chtimes(p, time.Time{}, modTime); err != nil {
+		return fmt.Errorf("failed to set modification time: %v", err)
+	}
+	return nil
+}
+
+// trackIncludes replaces the files which the file on p includes.
+func (fs *Fileserver) trackIncludes(p string, deps []string) {
+	if _, tracked := fs.includesOf[p]; !tracked && len(deps) == 0 {
+		return
+	}
+
+	if fs.dependents == nil {
+		fs.dependents = map[string]map[string]bool{}
+		fs.includesOf = map[string][]string{}
+	}
+
+	for _, dep := range fs.includesOf[p] {
+		delete(fs.dependents[dep], p)
+	}
+
+	fs.includesOf[p] = deps
+	for _, dep := range deps {
+		if fs.dependents[dep] == nil {
+			fs.dependents[dep] = map[string]bool{}
+		}
+		fs.dependents[dep][p] = true
+	}
+}
+
+// updateTemplates renders all templates again, after a layout or data file was created.
+func (fs *Fileserver) updateTemplates() {
+	var pages []string
+	filepath.WalkDir(fs.masterPath, func(p string, d iofs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && isTemplate(p) {
+			pages = append(pages, p)
+		}
+		return nil
+	})
+
+	for _, page := range pages {
+		// the new layout or data file is not among the dependencies the page was last mirrored with
+		delete(fs.manifest.Files, filepath.ToSlash(fs.outputPath(strings.Replace(page, fs.masterPath, "", -1))))
+	}
+	fs.updateFiles(pages, "")
+}
+
+// updateDependents mirrors the files including the changed file on p again, and reloads them.
+func (fs *Fileserver) updateDependents(p string) {
+	fs.updateFiles(slices.Sorted(maps.Keys(fs.dependents[p])), p)
+}
+
+// updateFiles mirrors the files again, after the file on include changed, and reloads the changed ones.
+func (fs *Fileserver) updateFiles(files []string, include string) {
+	for _, dependent := range files {
+		relativePath := strings.Replace(dependent, fs.masterPath, "", -1)
+		changed, err := fs.mirrorFile(dependent)
+		if err != nil {
+			ancli.Err("failed to mirror file including changed file", "path", dependent, "include", include, "err", err)
+			events.Err(err, relativePath, dependent)
+			continue
+		}
+
+		if changed {
+			ancli.Debug("mirrored file including changed file", "path", dependent, "include", include)
+			fs.notifyPageUpdate(dependent)
+		}
+	}
+}
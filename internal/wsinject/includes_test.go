@@ -0,0 +1,76 @@
+package wsinject
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_includes(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "partials"), 0o755)
+	nav := filepath.Join(root, "partials", "nav.html")
+	os.WriteFile(nav, []byte("<nav>v1</nav>"), 0o644)
+	page := strings.Replace(mockHtml, "<body>", `<body><!--#include virtual="/partials/nav.html" -->`, 1)
+	os.WriteFile(filepath.Join(root, "index.html"), []byte(page), 0o644)
+
+	fs := newTestFileServer(t)
+	if _, err := fs.Setup(root); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+
+	t.Run("it should resolve includes when mirroring", func(t *testing.T) {
+		b, _ := os.ReadFile(filepath.Join(fs.mirrorPath, "index.html"))
+		if !strings.Contains(string(b), "<nav>v1</nav>") || !strings.Contains(string(b), "delta-streamer.js") {
+			t.Fatalf("expected mirrored page to include nav and be injected, got: %v", string(b))
+		}
+	})
+
+	t.Run("it should reload pages including a changed partial", func(t *testing.T) {
+		refreshChan := make(chan string)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		t.Cleanup(cancel)
+		go fs.Watch(ctx, notifyTo(ctx, refreshChan))
+		time.Sleep(time.Millisecond)
+
+		os.WriteFile(nav, []byte("<nav>v2</nav>"), 0o644)
+		var got []string
+		for len(got) < 2 {
+			select {
+			case p := <-refreshChan:
+				got = append(got, p)
+			case <-ctx.Done():
+				t.Fatalf("expected reloads of partial and page, got: %v", got)
+			}
+		}
+
+		if !slices.Contains(got, "/index.html") {
+			t.Fatalf("expected reload of including page, got: %v", got)
+		}
+
+		b, _ := os.ReadFile(filepath.Join(fs.mirrorPath, "index.html"))
+		if !strings.Contains(string(b), "<nav>v2</nav>") {
+			t.Fatalf("expected mirrored page to include changed nav, got: %v", string(b))
+		}
+	})
+
+	t.Run("it should change the modification time of pages when a partial changes", func(t *testing.T) {
+		mirrored := filepath.Join(fs.mirrorPath, "index.html")
+		before, _ := os.Stat(mirrored)
+		// the same length, so that only the modification time tells the versions apart
+		os.WriteFile(nav, []byte("<nav>v3</nav>"), 0o644)
+		later := before.ModTime().Add(time.Minute)
+		os.Chtimes(nav, later, later)
+		fs.updateDependents(nav)
+
+		after, _ := os.Stat(mirrored)
+		if after.Size() != before.Size() || !after.ModTime().Equal(later) {
+			t.Fatalf("expected the page to take the modification time of the partial: %v, got: %v", later, after.ModTime())
+		}
+	})
+}
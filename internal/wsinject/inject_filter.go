@@ -0,0 +1,127 @@
+package wsinject
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultInjectExtensions are the file extensions which are injected into by default.
+var DefaultInjectExtensions = []string{".html", ".htm", ".xhtml", ".shtml"}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// injectFilter decides which mirrored files get the delta-streamer script injected.
+// Excluded files are never injected, included files and files with one of the extensions
+// always are. Other files are injected if sniffing detects html content.
+type injectFilter struct {
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+	extensions []string
+	noSniff    bool
+}
+
+// WithInjectInclude injects into files matching any of the globs, regardless of their content.
+// See WithInjectExclude for the glob syntax.
+func WithInjectInclude(globs ...string) Option {
+	return func(fs *Fileserver) {
+		for _, g := range globs {
+			fs.injectFilter.include = append(fs.injectFilter.include, compileGlob(g))
+		}
+	}
+}
+
+// WithInjectExclude never injects into files matching any of the globs, such as 'coverage/**'.
+// Globs are matched against the slash separated path relative to the served directory,
+// or against the file name if they contain no '/'. '*' matches within a path segment
+// and '**' across segments.
+func WithInjectExclude(globs ...string) Option {
+	return func(fs *Fileserver) {
+		for _, g := range globs {
+			fs.injectFilter.exclude = append(fs.injectFilter.exclude, compileGlob(g))
+		}
+	}
+}
+
+// WithInjectExtensions replaces the DefaultInjectExtensions.
+func WithInjectExtensions(extensions ...string) Option {
+	return func(fs *Fileserver) {
+		fs.injectFilter.extensions = nil
+		for _, ext := range extensions {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext == "" {
+				continue
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			fs.injectFilter.extensions = append(fs.injectFilter.extensions, ext)
+		}
+	}
+}
+
+// WithInjectSniffing enables or disables injecting into files which are
+// detected as html by their content, but not by their extension. Enabled by default.
+func WithInjectSniffing(enabled bool) Option {
+	return func(fs *Fileserver) {
+		fs.injectFilter.noSniff = !enabled
+	}
+}
+
+// compileGlob converts the glob into a regular expression. Globs without a '/' match the file name.
+func compileGlob(glob string) *regexp.Regexp {
+	glob = strings.TrimPrefix(glob, "/")
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+func matchesAny(patterns []*regexp.Regexp, relPath string) bool {
+	return slices.ContainsFunc(patterns, func(re *regexp.Regexp) bool {
+		return re.MatchString(relPath)
+	})
+}
+
+// shouldInject reports if the file on relPath, relative to the served directory, is injected into.
+func (f *injectFilter) shouldInject(relPath string, b []byte) bool {
+	relPath = strings.TrimPrefix(path.Clean("/"+relPath), "/")
+	if matchesAny(f.exclude, relPath) {
+		return false
+	}
+
+	if matchesAny(f.include, relPath) || slices.Contains(f.extensions, strings.ToLower(path.Ext(relPath))) {
+		return true
+	}
+
+	return !f.noSniff && sniffHTML(b)
+}
+
+// sniffHTML detects html content, also after a byte order mark, which
+// makes http.DetectContentType report plain text.
+func sniffHTML(b []byte) bool {
+	b = bytes.TrimPrefix(b, utf8BOM)
+	return strings.Contains(http.DetectContentType(b), "text/html")
+}
@@ -0,0 +1,70 @@
+package wsinject
+
+import (
+	"testing"
+)
+
+func Test_compileGlob(t *testing.T) {
+	tests := []struct {
+		glob string
+		path string
+		want bool
+	}{
+		{glob: "*.html", path: "index.html", want: true},
+		{glob: "*.html", path: "docs/index.html", want: true},
+		{glob: "*.html", path: "index.htm", want: false},
+		{glob: "coverage/**", path: "coverage/lcov-report/index.html", want: true},
+		{glob: "coverage/**", path: "src/coverage/index.html", want: false},
+		{glob: "/coverage/*", path: "coverage/index.html", want: true},
+		{glob: "coverage/*", path: "coverage/lcov-report/index.html", want: false},
+		{glob: "**/vendor/*.html", path: "a/b/vendor/x.html", want: true},
+		{glob: "**/vendor/*.html", path: "vendor/x.html", want: true},
+		{glob: "page?.tmpl", path: "page1.tmpl", want: true},
+		{glob: "page?.tmpl", path: "page10.tmpl", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.path, func(t *testing.T) {
+			if got := compileGlob(tt.glob).MatchString(tt.path); got != tt.want {
+				t.Fatalf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_shouldInject(t *testing.T) {
+	newFilter := func(opts ...Option) injectFilter {
+		fs := &Fileserver{injectFilter: injectFilter{extensions: DefaultInjectExtensions}}
+		for _, opt := range opts {
+			opt(fs)
+		}
+		return fs.injectFilter
+	}
+
+	const fragment = "<section>hi</section>"
+	tests := []struct {
+		name    string
+		filter  injectFilter
+		relPath string
+		content string
+		want    bool
+	}{
+		{name: "it should inject by default extension", filter: newFilter(), relPath: "/partial.shtml", content: fragment, want: true},
+		{name: "it should sniff html without extension", filter: newFilter(), relPath: "/page", content: mockHtml, want: true},
+		{name: "it should sniff html after a byte order mark", filter: newFilter(), relPath: "/page", content: "\xef\xbb\xbf" + mockHtml, want: true},
+		{name: "it should not inject into other files", filter: newFilter(), relPath: "/app.js", content: "const head = '</head>';", want: false},
+		{name: "it should not sniff if disabled", filter: newFilter(WithInjectSniffing(false)), relPath: "/page", content: mockHtml, want: false},
+		{name: "it should inject into included files", filter: newFilter(WithInjectInclude("*.tmpl")), relPath: "/views/page.tmpl", content: fragment, want: true},
+		{name: "it should use configured extensions", filter: newFilter(WithInjectExtensions("php", ".HTML")), relPath: "/index.php", content: fragment, want: true},
+		{name: "it should replace default extensions", filter: newFilter(WithInjectExtensions("php")), relPath: "/partial.shtml", content: fragment, want: false},
+		{name: "it should prefer excludes", filter: newFilter(WithInjectExclude("coverage/**"), WithInjectInclude("*.html")), relPath: "/coverage/index.html", content: mockHtml, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.shouldInject(tt.relPath, []byte(tt.content)); got != tt.want {
+				t.Fatalf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package wsinject
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pchchv/sws/helpers/ancli"
+)
+
+const (
+	manifestFileName = "manifest.json"
+	// persistentMirrorSite is the directory of a persistent mirror which holds the mirrored files,
+	// next to the manifest, so that the manifest is not served
+	persistentMirrorSite = "site"
+)
+
+type manifestEntry struct {
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+	// ModTime is the modification time of the original in nanoseconds, files with the same
+	// size and modification time are not hashed again
+	ModTime int64 `json:"mtime,omitempty"`
+	// Deps holds the content hashes of the included files, by path, empty for missing files
+	Deps map[string]string `json:"deps,omitempty"`
+}
+
+// manifest holds the content hashes of the mirrored files, by path relative to the served directory.
+// It is used to skip files which have not changed, when restarting with a persistent mirror
+// and when a file is written with identical content.
+type manifest struct {
+	// Key identifies the configuration the mirror was made with, the manifest is discarded if it changes.
+	Key   string                   `json:"key"`
+	Files map[string]manifestEntry `json:"files"`
+	// path is empty for manifests which are not persisted
+	path string
+	seen map[string]bool
+}
+
+func newManifest(key string) *manifest {
+	return &manifest{
+		Key:   key,
+		Files: map[string]manifestEntry{},
+		seen:  map[string]bool{},
+	}
+}
+
+// loadManifest loads the manifest on p, or returns an empty one if it doesn't exist or key differs.
+func loadManifest(p, key string) (*manifest, error) {
+	m := newManifest(key)
+	m.path = p
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var stored manifest
+	if err := json.Unmarshal(b, &stored); err != nil || stored.Key != key || stored.Files == nil {
+		// the mirror is rebuilt from scratch
+		return m, nil
+	}
+	m.Files = stored.Files
+	return m, nil
+}
+
+func (m *manifest) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+
+	// written to a temporary file first, to never leave a partially written manifest
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// unchanged reports if the file on relPath, and the files it includes, had the same content
+// when it was last mirrored, and marks it as seen. The last entry is returned.
+func (m *manifest) unchanged(relPath string, entry manifestEntry) (manifestEntry, bool) {
+	m.seen[relPath] = true
+	prev, ok := m.Files[relPath]
+	if !ok || prev.Hash != entry.Hash || prev.Size != entry.Size {
+		return prev, false
+	}
+
+	// files of temporary mirrors are not hashed, they are unchanged if they weren't modified
+	if entry.Hash == "" && prev.ModTime != entry.ModTime {
+		return prev, false
+	}
+
+	for dep, hash := range prev.Deps {
+		if current, _ := hashContent(dep); current != hash {
+			return prev, false
+		}
+	}
+	return prev, true
+}
+
+// removeUnseen removes the files which were not seen since the manifest was loaded
+// from the mirror, such as files deleted while sws wasn't running.
+func (m *manifest) removeUnseen(mirrorPath string) {
+	for relPath := range m.Files {
+		if m.seen[relPath] {
+			continue
+		}
+		os.Remove(filepath.Join(mirrorPath, filepath.FromSlash(relPath)))
+		delete(m.Files, relPath)
+	}
+}
+
+// contentHash returns the content hash of the file on origPath with info. It is taken from the entry
+// of relPath if the size and modification time are unchanged, so that unchanged files are not read.
+// With pollHash, modification times are too coarse to rely on, and files are always hashed.
+// Files of temporary mirrors are not hashed, as their manifest starts empty on every start,
+// so hashes would rarely be reused and only slow down the setup.
+func (fs *Fileserver) contentHash(relPath, origPath string, info os.FileInfo) (string, error) {
+	if !fs.persistentMirror {
+		return "", nil
+	}
+
+	prev, ok := fs.manifest.Files[relPath]
+	if ok && !fs.pollHash && prev.Hash != "" && prev.Size == info.Size() && prev.ModTime == info.ModTime().UnixNano() {
+		return prev.Hash, nil
+	}
+	return hashContent(origPath)
+}
+
+func hashContent(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// persistentMirrorDir returns the directory of the persistent mirror of root, within cacheDir,
+// which defaults to the user cache directory.
+func persistentMirrorDir(cacheDir, root string) (string, error) {
+	if cacheDir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find user cache dir: %v", err)
+		}
+		cacheDir = filepath.Join(userCache, "sws", "mirrors")
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := strings.Trim(filepath.Base(abs), string(filepath.Separator)+".")
+	if name == "" {
+		name = "root"
+	}
+	return filepath.Join(cacheDir, name+"-"+hex.EncodeToString(sum[:])[:12]), nil
+}
+
+// manifestKey identifies the settings which change the content of mirrored files.
+func (fs *Fileserver) manifestKey() string {
+	h := sha256.New()
+	fmt.Fprintln(h, fs.liveReload.ScriptTag())
+	for _, re := range fs.injectFilter.include {
+		fmt.Fprintln(h, "include", re.String())
+	}
+	for _, re := range fs.injectFilter.exclude {
+		fmt.Fprintln(h, "exclude", re.String())
+	}
+	fmt.Fprintln(h, fs.injectFilter.extensions, fs.injectFilter.noSniff, fs.includes)
+	if fs.templates != nil {
+		fmt.Fprintln(h, "templates", fs.templates.layoutsDir, fs.templates.dataDir)
+	}
+	for _, t := range fs.transformers {
+		fmt.Fprintln(h, "transformer", t.Name())
+		if k, ok := t.(Keyer); ok {
+			fmt.Fprintln(h, "key", k.Key())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// setupMirror creates a temporary mirror directory or, for persistent mirrors,
+// the per project mirror directory and loads its manifest.
+func (fs *Fileserver) setupMirror(root string) error {
+	if !fs.persistentMirror {
+		mirrorDir, err := os.MkdirTemp("", "sws_*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary mirror dir: %v", err)
+		}
+		fs.mirrorPath = mirrorDir
+		fs.manifest = newManifest(fs.manifestKey())
+		return nil
+	}
+
+	dir, err := persistentMirrorDir(fs.persistentMirrorCache, root)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := loadManifest(filepath.Join(dir, manifestFileName), fs.manifestKey())
+	if err != nil {
+		return err
+	}
+	fs.manifest = manifest
+
+	fs.mirrorPath = filepath.Join(dir, persistentMirrorSite)
+	if len(manifest.Files) == 0 {
+		// files of a mirror without a matching manifest are unknown, so it is rebuilt
+		if err := os.RemoveAll(fs.mirrorPath); err != nil {
+			return fmt.Errorf("failed to clear persistent mirror dir: %v", err)
+		}
+	}
+	if err := os.MkdirAll(fs.mirrorPath, 0o755); err != nil {
+		return fmt.Errorf("failed to create persistent mirror dir: %v", err)
+	}
+	ancli.Notice("using persistent mirror", "path", fs.mirrorPath, "files", len(manifest.Files))
+	return nil
+}
@@ -0,0 +1,165 @@
+package wsinject
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_WithPersistentMirror(t *testing.T) {
+	root := t.TempDir()
+	cache := t.TempDir()
+	os.WriteFile(filepath.Join(root, "index.html"), []byte(mockHtml), 0o644)
+	os.WriteFile(filepath.Join(root, "about.html"), []byte(mockHtml), 0o644)
+	os.WriteFile(filepath.Join(root, "removed.css"), []byte("body{}"), 0o644)
+
+	start := func(t *testing.T, opts ...Option) *Fileserver {
+		t.Helper()
+		fs := newTestFileServer(t, append([]Option{WithPersistentMirror(cache)}, opts...)...)
+		if _, err := fs.Setup(root); err != nil {
+			t.Fatalf("failed to setup: %v", err)
+		}
+
+		if err := fs.Close(); err != nil {
+			t.Fatalf("failed to close: %v", err)
+		}
+		return fs
+	}
+
+	first := start(t)
+	if _, err := os.Stat(filepath.Join(first.mirrorPath, "index.html")); err != nil {
+		t.Fatalf("expected persistent mirror to be kept after close: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(root, "about.html"), []byte(mockHtml+"<!-- changed -->"), 0o644)
+	os.Remove(filepath.Join(root, "removed.css"))
+	second := start(t)
+
+	t.Run("it should use the same mirror for the same project", func(t *testing.T) {
+		if first.mirrorPath != second.mirrorPath {
+			t.Fatalf("expected mirror path: %v, got: %v", first.mirrorPath, second.mirrorPath)
+		}
+	})
+
+	t.Run("it should only rewrite changed files", func(t *testing.T) {
+		if got := second.stats.placed[placeUnchanged]; got != 1 {
+			t.Fatalf("expected 1 unchanged file, got: %v", got)
+		}
+
+		if got := second.stats.placed[placeInjected]; got != 1 {
+			t.Fatalf("expected 1 injected file, got: %v", got)
+		}
+	})
+
+	t.Run("it should remove files which were deleted from the mirror", func(t *testing.T) {
+		if _, err := os.Stat(filepath.Join(second.mirrorPath, "removed.css")); !os.IsNotExist(err) {
+			t.Fatalf("expected removed file to be deleted from the mirror, got: %v", err)
+		}
+	})
+
+	t.Run("it should rebuild the mirror if the injection settings change", func(t *testing.T) {
+		third := start(t, WithInjectExclude("about.html"))
+		if got := third.stats.placed[placeUnchanged]; got != 0 {
+			t.Fatalf("expected no unchanged files, got: %v", got)
+		}
+	})
+}
+
+func Test_Close(t *testing.T) {
+	fs := newTestFileServer(t)
+	if _, err := fs.Setup(t.TempDir()); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+
+	if err := fs.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	if _, err := os.Stat(fs.mirrorPath); !os.IsNotExist(err) {
+		t.Fatalf("expected temporary mirror to be removed, got: %v", err)
+	}
+}
+
+func Test_mirrorFile_unchanged(t *testing.T) {
+	setup := func(t *testing.T, opts ...Option) (*Fileserver, string) {
+		t.Helper()
+		root := t.TempDir()
+		p := filepath.Join(root, "index.html")
+		os.WriteFile(p, []byte(mockHtml), 0o644)
+		fs := newTestFileServer(t, opts...)
+		if _, err := fs.Setup(root); err != nil {
+			t.Fatalf("failed to setup: %v", err)
+		}
+		t.Cleanup(func() { fs.Close() })
+		return fs, p
+	}
+
+	t.Run("it should skip identical writes to persistent mirrors", func(t *testing.T) {
+		fs, p := setup(t, WithPersistentMirror(t.TempDir()))
+		os.WriteFile(p, []byte(mockHtml), 0o644)
+		if changed, err := fs.mirrorFile(p); err != nil || changed {
+			t.Fatalf("expected identical write to be unchanged, got: %v, err: %v", changed, err)
+		}
+
+		os.WriteFile(p, []byte(mockHtml+" "), 0o644)
+		if changed, err := fs.mirrorFile(p); err != nil || !changed {
+			t.Fatalf("expected write to be changed, got: %v, err: %v", changed, err)
+		}
+	})
+
+	t.Run("it should not hash the files of temporary mirrors", func(t *testing.T) {
+		fs, p := setup(t)
+		if got := fs.manifest.Files["/index.html"].Hash; got != "" {
+			t.Fatalf("expected no hash, got: %v", got)
+		}
+
+		if changed, err := fs.mirrorFile(p); err != nil || changed {
+			t.Fatalf("expected unmodified file to be unchanged, got: %v, err: %v", changed, err)
+		}
+
+		later := time.Now().Add(time.Second)
+		os.Chtimes(p, later, later)
+		if changed, err := fs.mirrorFile(p); err != nil || !changed {
+			t.Fatalf("expected modified file to be changed, got: %v, err: %v", changed, err)
+		}
+	})
+}
+
+func Test_contentHash(t *testing.T) {
+	root := t.TempDir()
+	p := filepath.Join(root, "logo.png")
+	os.WriteFile(p, []byte("png"), 0o644)
+	fs := newTestFileServer(t, WithPersistentMirror(t.TempDir()))
+	if _, err := fs.Setup(root); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+
+	info, _ := os.Stat(p)
+	want, _ := hashContent(p)
+	fs.manifest.Files["/logo.png"] = manifestEntry{Hash: "stored", Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+
+	t.Run("it should not hash files with unchanged size and modification time", func(t *testing.T) {
+		if got, err := fs.contentHash("/logo.png", p, info); err != nil || got != "stored" {
+			t.Fatalf("expected the stored hash, got: %v, err: %v", got, err)
+		}
+	})
+
+	t.Run("it should hash files with a changed modification time", func(t *testing.T) {
+		later := info.ModTime().Add(time.Second)
+		os.Chtimes(p, later, later)
+		info, _ := os.Stat(p)
+		if got, err := fs.contentHash("/logo.png", p, info); err != nil || got != want {
+			t.Fatalf("expected: %v, got: %v, err: %v", want, got, err)
+		}
+	})
+
+	t.Run("it should always hash with pollHash", func(t *testing.T) {
+		fs.pollHash = true
+		t.Cleanup(func() { fs.pollHash = false })
+		if got, err := fs.contentHash("/logo.png", p, info); err != nil || got != want {
+			t.Fatalf("expected: %v, got: %v, err: %v", want, got, err)
+		}
+	})
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package wsinject
+
+func peakMemory() (uint64, bool) {
+	return 0, false
+}
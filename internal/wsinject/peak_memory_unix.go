@@ -0,0 +1,22 @@
+//go:build unix
+
+package wsinject
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// peakMemory returns the peak resident set size of the process in bytes.
+func peakMemory() (uint64, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+
+	// reported in kilobytes, except on darwin
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return uint64(usage.Maxrss), true
+	}
+	return uint64(usage.Maxrss) * 1024, true
+}
@@ -0,0 +1,9 @@
+package wsinject
+
+import "golang.org/x/sys/unix"
+
+// reflink clones src to dst, sharing the data blocks until either is written to.
+// Supported by APFS.
+func reflink(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
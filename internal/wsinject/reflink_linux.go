@@ -0,0 +1,34 @@
+package wsinject
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink clones src to dst, sharing the data blocks until either is written to.
+// Supported by filesystems such as btrfs and xfs.
+func reflink(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	info, err := s.Stat()
+	if err != nil {
+		return err
+	}
+
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if err := unix.IoctlFileClone(int(d.Fd()), int(s.Fd())); err != nil {
+		d.Close()
+		os.Remove(dst)
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,108 @@
+package wsinject
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pchchv/sws/helpers/ancli"
+)
+
+// WithFollowSymlinks mirrors and watches the contents of symlinked directories,
+// also if they resolve outside of the served directory. Changes to them are
+// reported under the path of the symlink.
+func WithFollowSymlinks() Option {
+	return func(fs *Fileserver) {
+		fs.followSymlinks = true
+	}
+}
+
+// walkFollowingSymlinks walks the file tree as filepath.WalkDir does, but also walks
+// symlinked directories, under the path of the symlink. Symlinks to a directory
+// which is being walked already are skipped, to not walk cycles forever.
+func walkFollowingSymlinks(root string, do fs.WalkDirFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return do(root, nil, err)
+	}
+
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return do(root, nil, err)
+	}
+
+	err = walkDir(root, real, fs.FileInfoToDirEntry(info), map[string]bool{real: true}, do)
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkDir walks p, whose symlinks are resolved in real. ancestors holds the real paths of the walked directories.
+func walkDir(p, real string, d fs.DirEntry, ancestors map[string]bool, do fs.WalkDirFunc) error {
+	if err := do(p, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			return nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		if err := do(p, d, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+			return err
+		}
+		return nil
+	}
+
+	for _, e := range entries {
+		child := filepath.Join(p, e.Name())
+		childReal := filepath.Join(real, e.Name())
+		if e.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(child)
+			if err != nil {
+				ancli.Warn("skipping broken symlink", "path", child, "err", err)
+				continue
+			}
+
+			if info.IsDir() {
+				if childReal, err = filepath.EvalSymlinks(child); err != nil {
+					ancli.Warn("skipping unresolvable symlink", "path", child, "err", err)
+					continue
+				}
+
+				if ancestors[childReal] {
+					ancli.Warn("skipping symlink cycle", "path", child, "target", childReal)
+					continue
+				}
+				e = fs.FileInfoToDirEntry(info)
+			}
+		}
+
+		if e.IsDir() {
+			ancestors[childReal] = true
+		}
+		err := walkDir(child, childReal, e, ancestors, do)
+		delete(ancestors, childReal)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logicalPaths returns the paths below the served directory of the file changed on p,
+// which differ from p if it is within a symlinked directory.
+func (fs *Fileserver) logicalPaths(p string) []string {
+	dirs, ok := fs.dirAliases[filepath.Dir(p)]
+	if !ok {
+		return []string{p}
+	}
+
+	paths := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		paths = append(paths, filepath.Join(dir, filepath.Base(p)))
+	}
+	return paths
+}
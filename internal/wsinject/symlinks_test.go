@@ -0,0 +1,89 @@
+package wsinject
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// setupSymlinkedTree creates a served directory with a symlink to a shared directory outside
+// of it, and a symlink cycle back to the served directory.
+func setupSymlinkedTree(t *testing.T) (string, string) {
+	t.Helper()
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "site")
+	shared := filepath.Join(tmp, "shared")
+	for _, dir := range []string{root, shared} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+
+	os.WriteFile(filepath.Join(root, "index.html"), []byte(mockHtml), 0o644)
+	os.WriteFile(filepath.Join(shared, "page.html"), []byte(mockHtml), 0o644)
+	if err := os.Symlink(shared, filepath.Join(root, "shared")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	os.Symlink(root, filepath.Join(shared, "cycle"))
+	return root, shared
+}
+
+func Test_walkFollowingSymlinks(t *testing.T) {
+	root, _ := setupSymlinkedTree(t)
+	var got []string
+	err := walkFollowingSymlinks(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			t.Fatalf("got err during traversal: %v", err)
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(root, p)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk: %v", err)
+	}
+
+	slices.Sort(got)
+	want := []string{"index.html", "shared/page.html"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected: %v, got: %v", want, got)
+	}
+}
+
+func Test_WithFollowSymlinks(t *testing.T) {
+	root, shared := setupSymlinkedTree(t)
+	fs := newTestFileServer(t, WithFollowSymlinks())
+	if _, err := fs.Setup(root); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+
+	t.Run("it should mirror symlinked directories", func(t *testing.T) {
+		if _, err := os.Stat(filepath.Join(fs.mirrorPath, "shared", "page.html")); err != nil {
+			t.Fatalf("expected symlinked file to be mirrored: %v", err)
+		}
+	})
+
+	t.Run("it should report changes to symlink targets under the symlinked path", func(t *testing.T) {
+		refreshChan := make(chan string)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		t.Cleanup(cancel)
+		go fs.Watch(ctx, notifyTo(ctx, refreshChan))
+		time.Sleep(time.Millisecond)
+
+		os.WriteFile(filepath.Join(shared, "page.html"), []byte("changes!"), 0o644)
+		select {
+		case got := <-refreshChan:
+			if want := "/shared/page.html"; got != want {
+				t.Fatalf("expected reload event to be %v, but got %v", want, got)
+			}
+		case <-ctx.Done():
+			t.Fatal("failed to receive refresh within time")
+		}
+	})
+}
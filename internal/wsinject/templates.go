@@ -0,0 +1,205 @@
+package wsinject
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateSuffix marks html files which are rendered with html/template, into a file without it.
+const templateSuffix = ".tmpl.html"
+
+// templates renders '*.tmpl.html' files with the layouts in layoutsDir and the data files in dataDir.
+type templates struct {
+	layoutsDir string
+	dataDir    string
+}
+
+// templateData is passed to rendered templates.
+type templateData struct {
+	// Data holds the content of the json and yaml files in the data directory,
+	// by their path without extension, such as '.Data.site.title' for 'site.yaml'.
+	Data map[string]any
+	Page templatePage
+}
+
+type templatePage struct {
+	// Path is the url path of the rendered page, such as '/blog/index.html'.
+	Path string
+}
+
+// WithTemplates renders files ending with '.tmpl.html' through html/template into files ending
+// with '.html'. All templates in layoutsDir are available to pages, such as a base layout which a page
+// executes with '{{template "base.html" .}}' after defining its blocks. The json and yaml files in dataDir
+// are passed to pages as '.Data'. Changing a layout or data file renders all pages again.
+func WithTemplates(layoutsDir, dataDir string) Option {
+	return func(fs *Fileserver) {
+		fs.templates = &templates{layoutsDir: layoutsDir, dataDir: dataDir}
+	}
+}
+
+func isTemplate(p string) bool {
+	return strings.HasSuffix(p, templateSuffix)
+}
+
+// outputPath returns the path a file is mirrored to, which differs for templates.
+func (fs *Fileserver) outputPath(p string) string {
+	if fs.templates != nil && isTemplate(p) {
+		return strings.TrimSuffix(p, templateSuffix) + ".html"
+	}
+	return p
+}
+
+// isTemplateInput reports if p is a layout or data file.
+func (t *templates) isTemplateInput(p string) bool {
+	for _, dir := range []string{t.layoutsDir, t.dataDir} {
+		if rel, err := filepath.Rel(dir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// files returns the files in dir with one of the extensions.
+func files(dir string, extensions ...string) []string {
+	var found []string
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && slices.Contains(extensions, strings.ToLower(filepath.Ext(p))) {
+			found = append(found, p)
+		}
+		return nil
+	})
+	return found
+}
+
+func (t *templates) loadData(dataFiles []string) (map[string]any, error) {
+	data := map[string]any{}
+	// owners holds the data file of each key, by its path of keys
+	owners := map[string]string{}
+	for _, p := range dataFiles {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var v any
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".json":
+			err = json.Unmarshal(b, &v)
+		default:
+			err = yaml.Unmarshal(b, &v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse data file: '%v', err: %v", p, err)
+		}
+
+		rel, _ := filepath.Rel(t.dataDir, p)
+		keys := strings.Split(strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel)), "/")
+		m := data
+		for i, key := range keys[:len(keys)-1] {
+			if owner, ok := owners[strings.Join(keys[:i+1], ".")]; ok {
+				return nil, fmt.Errorf("data file: '%v' collides with: '%v' on key: '%v'", p, owner, strings.Join(keys[:i+1], "."))
+			}
+
+			nested, ok := m[key].(map[string]any)
+			if !ok {
+				nested = map[string]any{}
+				m[key] = nested
+			}
+			m = nested
+		}
+
+		keyPath := strings.Join(keys, ".")
+		if _, exists := m[keys[len(keys)-1]]; exists {
+			return nil, fmt.Errorf("data file: '%v' collides with: '%v' on key: '%v'", p, ownerOf(owners, keyPath), keyPath)
+		}
+		m[keys[len(keys)-1]] = v
+		owners[keyPath] = p
+	}
+	return data, nil
+}
+
+// ownerOf returns the data file of the key on keyPath, or else of the first key below it.
+func ownerOf(owners map[string]string, keyPath string) string {
+	if owner, ok := owners[keyPath]; ok {
+		return owner
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(owners)) {
+		if strings.HasPrefix(k, keyPath+".") {
+			return owners[k]
+		}
+	}
+	return ""
+}
+
+// render executes the page template b on pagePath. It returns the rendered page and
+// the layout and data files it depends on.
+func (t *templates) render(pagePath, urlPath string, b []byte) ([]byte, []string, error) {
+	layouts := files(t.layoutsDir, ".html")
+	dataFiles := files(t.dataDir, ".json", ".yaml", ".yml")
+	deps := append(slices.Clone(layouts), dataFiles...)
+
+	root := template.New(filepath.Base(pagePath))
+	for _, layout := range layouts {
+		lb, err := os.ReadFile(layout)
+		if err != nil {
+			return nil, deps, err
+		}
+
+		name, _ := filepath.Rel(t.layoutsDir, layout)
+		if _, err := root.New(filepath.ToSlash(name)).Parse(string(lb)); err != nil {
+			return nil, deps, err
+		}
+	}
+
+	if _, err := root.Parse(string(b)); err != nil {
+		return nil, deps, err
+	}
+
+	data, err := t.loadData(dataFiles)
+	if err != nil {
+		return nil, deps, err
+	}
+
+	var buf bytes.Buffer
+	if err := root.Execute(&buf, templateData{Data: data, Page: templatePage{Path: urlPath}}); err != nil {
+		return nil, deps, err
+	}
+	return buf.Bytes(), deps, nil
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Template error</title>
+</head>
+<body style="font-family: monospace; padding: 2rem;">
+  <h1>Failed to render {{.Path}}</h1>
+  <pre style="white-space: pre-wrap; color: #b00020;">{{.Err}}</pre>
+</body>
+</html>
+`))
+
+// renderTemplate renders the template on origPath, or on errors an error page,
+// which is injected as well so that it reloads once the template is fixed.
+func (fs *Fileserver) renderTemplate(origPath, urlPath string, b []byte) ([]byte, []string, error) {
+	rendered, deps, err := fs.templates.render(origPath, urlPath, b)
+	if err == nil {
+		return rendered, deps, nil
+	}
+
+	var buf bytes.Buffer
+	errorPage.Execute(&buf, map[string]string{"Path": strings.Replace(origPath, fs.masterPath, "", -1), "Err": err.Error()})
+	return buf.Bytes(), deps, fmt.Errorf("failed to render template: %v", err)
+}
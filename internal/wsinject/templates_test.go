@@ -0,0 +1,148 @@
+package wsinject
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_WithTemplates(t *testing.T) {
+	setup := func(t *testing.T, page string) (*Fileserver, string) {
+		t.Helper()
+		root := t.TempDir()
+		files := map[string]string{
+			"_layouts/base.html":   `<!DOCTYPE html><html><head><title>{{.Data.site.title}}</title></head><body>{{block "content" .}}{{end}}</body></html>`,
+			"_data/site.yaml":      "title: My site\n",
+			"_data/nav/links.json": `[{"href": "/", "name": "Home"}]`,
+			"index.tmpl.html":      page,
+		}
+		for name, content := range files {
+			p := filepath.Join(root, filepath.FromSlash(name))
+			os.MkdirAll(filepath.Dir(p), 0o755)
+			os.WriteFile(p, []byte(content), 0o644)
+		}
+
+		fs := newTestFileServer(t,
+			WithTemplates(filepath.Join(root, "_layouts"), filepath.Join(root, "_data")))
+		if _, err := fs.Setup(root); err != nil {
+			t.Fatalf("failed to setup: %v", err)
+		}
+		t.Cleanup(func() { fs.Close() })
+		return fs, root
+	}
+
+	readMirrored := func(t *testing.T, fs *Fileserver) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(fs.mirrorPath, "index.html"))
+		if err != nil {
+			t.Fatalf("failed to read rendered page: %v", err)
+		}
+		return string(b)
+	}
+
+	const page = `{{define "content"}}{{range .Data.nav.links}}<a href="{{.href}}">{{.name}}</a>{{end}} on {{.Page.Path}}{{end}}{{template "base.html" .}}`
+
+	t.Run("it should render templates with layouts and data", func(t *testing.T) {
+		fs, _ := setup(t, page)
+		got := readMirrored(t, fs)
+		for _, want := range []string{"<title>My site</title>", `<a href="/">Home</a> on /index.html`, "delta-streamer.js"} {
+			if !strings.Contains(got, want) {
+				t.Fatalf("expected rendered page to contain: '%v', got: %v", want, got)
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(fs.mirrorPath, "index.tmpl.html")); !os.IsNotExist(err) {
+			t.Fatalf("expected template source not to be mirrored, got: %v", err)
+		}
+	})
+
+	t.Run("it should render template errors as an injected error page", func(t *testing.T) {
+		fs, _ := setup(t, `{{template "missing.html" .}}`)
+		got := readMirrored(t, fs)
+		if !strings.Contains(got, "Failed to render") || !strings.Contains(got, "delta-streamer.js") {
+			t.Fatalf("expected injected error page, got: %v", got)
+		}
+	})
+
+	t.Run("it should render and reload pages again when a data file changes", func(t *testing.T) {
+		fs, root := setup(t, page)
+		refreshChan := make(chan string)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		t.Cleanup(cancel)
+		go fs.Watch(ctx, notifyTo(ctx, refreshChan))
+		time.Sleep(time.Millisecond)
+
+		os.WriteFile(filepath.Join(root, "_data", "site.yaml"), []byte("title: Renamed\n"), 0o644)
+		var got []string
+		for !slices.Contains(got, "/index.html") {
+			select {
+			case p := <-refreshChan:
+				got = append(got, p)
+			case <-ctx.Done():
+				t.Fatalf("expected reload of rendered page, got: %v", got)
+			}
+		}
+
+		if rendered := readMirrored(t, fs); !strings.Contains(rendered, "<title>Renamed</title>") {
+			t.Fatalf("expected page to be rendered with changed data, got: %v", rendered)
+		}
+	})
+
+	t.Run("it should render pages again when a data file is created", func(t *testing.T) {
+		fs, root := setup(t, `{{.Data.footer.text}}`)
+		refreshChan := make(chan string)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		t.Cleanup(cancel)
+		go fs.Watch(ctx, notifyTo(ctx, refreshChan))
+		time.Sleep(time.Millisecond)
+
+		os.WriteFile(filepath.Join(root, "_data", "footer.yaml"), []byte("text: New footer\n"), 0o644)
+		var got []string
+		for !slices.Contains(got, "/index.html") {
+			select {
+			case p := <-refreshChan:
+				got = append(got, p)
+			case <-ctx.Done():
+				t.Fatalf("expected reload of rendered page, got: %v", got)
+			}
+		}
+
+		if rendered := readMirrored(t, fs); !strings.Contains(rendered, "New footer") {
+			t.Fatalf("expected page to be rendered with the new data, got: %v", rendered)
+		}
+	})
+}
+
+func Test_loadData(t *testing.T) {
+	t.Run("it should return an error for data files with colliding keys", func(t *testing.T) {
+		dataDir := t.TempDir()
+		os.MkdirAll(filepath.Join(dataDir, "nav"), 0o755)
+		os.WriteFile(filepath.Join(dataDir, "nav.json"), []byte(`{"title": "Nav"}`), 0o644)
+		os.WriteFile(filepath.Join(dataDir, "nav", "links.json"), []byte(`[]`), 0o644)
+		tmpl := &templates{dataDir: dataDir}
+
+		for _, dataFiles := range [][]string{
+			{filepath.Join(dataDir, "nav.json"), filepath.Join(dataDir, "nav", "links.json")},
+			{filepath.Join(dataDir, "nav", "links.json"), filepath.Join(dataDir, "nav.json")},
+		} {
+			_, err := tmpl.loadData(dataFiles)
+			if err == nil || !strings.Contains(err.Error(), "collides") || !strings.Contains(err.Error(), "key: 'nav'") {
+				t.Fatalf("expected collision error on key nav, got: %v", err)
+			}
+		}
+	})
+
+	t.Run("it should return an error for data files with the same key", func(t *testing.T) {
+		dataDir := t.TempDir()
+		os.WriteFile(filepath.Join(dataDir, "site.json"), []byte(`{}`), 0o644)
+		os.WriteFile(filepath.Join(dataDir, "site.yaml"), []byte("a: 1\n"), 0o644)
+		tmpl := &templates{dataDir: dataDir}
+		if _, err := tmpl.loadData([]string{filepath.Join(dataDir, "site.json"), filepath.Join(dataDir, "site.yaml")}); err == nil {
+			t.Fatal("expected collision error")
+		}
+	})
+}
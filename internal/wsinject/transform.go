@@ -0,0 +1,181 @@
+package wsinject
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/internal/events"
+	"github.com/pchchv/sws/internal/includes"
+)
+
+// File describes a file which is mirrored, to transformers.
+type File struct {
+	// Path is the url path the file is mirrored to, such as '/blog/index.html'.
+	Path string
+	// Source is the path of the original file.
+	Source string
+	// ContentType is the mime type of the content, by the extension of Path or else sniffed.
+	ContentType string
+}
+
+// Transformer transforms the content of mirrored files.
+type Transformer interface {
+	// Name identifies the transformer in logs and errors. Persistent mirrors are only rebuilt when the
+	// names of the transformers change, transformers with settings that change their output implement Keyer.
+	Name() string
+	// Match reports if the file is transformed, head holds the first bytes of its current content.
+	Match(f File, head []byte) bool
+	// Transform returns the transformed content and the paths of the files it depends on,
+	// which update the file when they change. On errors, returned content is still used,
+	// or else the content is left as it was.
+	Transform(f File, b []byte) ([]byte, []string, error)
+}
+
+// Keyer is optionally implemented by a Transformer whose output depends on more than its name, such as
+// on its version or options. Persistent mirrors are rebuilt when the key changes.
+type Keyer interface {
+	Key() string
+}
+
+// TransformError attributes an error to the transformer which produced it.
+type TransformError struct {
+	Transformer string
+	Path        string
+	Err         error
+}
+
+func (e *TransformError) Error() string {
+	return fmt.Sprintf("transformer %v failed on: '%v', err: %v", e.Transformer, e.Path, e.Err)
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// WithTransformer adds t to the pipeline of transformers mirrored files pass through,
+// after the built-in template and include transformers and before the script injection.
+// Transformers run in the order they are added.
+func WithTransformer(t Transformer) Option {
+	return func(fs *Fileserver) {
+		fs.transformers = append(fs.transformers, t)
+	}
+}
+
+// pipeline returns the transformers in the order they run.
+func (fs *Fileserver) pipeline() []Transformer {
+	var ts []Transformer
+	if fs.templates != nil {
+		ts = append(ts, templateTransformer{fs})
+	}
+	if fs.includes {
+		ts = append(ts, includesTransformer{fs})
+	}
+	ts = append(ts, fs.transformers...)
+	return append(ts, injectTransformer{fs})
+}
+
+// file returns the File of the content with head on outputPath.
+func file(outputPath, origPath string, head []byte) File {
+	contentType := mime.TypeByExtension(path.Ext(outputPath))
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+	return File{Path: outputPath, Source: origPath, ContentType: contentType}
+}
+
+// matches reports if any transformer of the pipeline transforms the file with head.
+func matches(pipeline []Transformer, f File, head []byte) bool {
+	for _, t := range pipeline {
+		if t.Match(f, head) {
+			return true
+		}
+	}
+	return false
+}
+
+// transform passes b through the transformers of pipeline which match it. Transformers are
+// matched against the content as transformed so far. It returns the transformed content, the
+// files it depends on and the names of the transformers which succeeded.
+func (fs *Fileserver) transform(pipeline []Transformer, f File, b []byte) ([]byte, []string, []string) {
+	var deps, applied []string
+	for _, t := range pipeline {
+		head := b[:min(len(b), sniffLen)]
+		current := file(f.Path, f.Source, head)
+		if !t.Match(current, head) {
+			continue
+		}
+
+		out, tDeps, err := t.Transform(current, b)
+		deps = append(deps, tDeps...)
+		if err != nil {
+			ancli.Warn("failed to transform file", "transformer", t.Name(), "path", f.Source, "err", err)
+			events.Err(&TransformError{Transformer: t.Name(), Path: f.Path, Err: err}, f.Path, f.Source)
+			if out != nil {
+				b = out
+			}
+			continue
+		}
+		b = out
+		applied = append(applied, t.Name())
+	}
+	return b, deps, applied
+}
+
+// templateTransformer renders templates, see WithTemplates.
+type templateTransformer struct {
+	fs *Fileserver
+}
+
+func (templateTransformer) Name() string {
+	return "templates"
+}
+
+func (t templateTransformer) Match(f File, head []byte) bool {
+	return isTemplate(f.Source)
+}
+
+func (t templateTransformer) Transform(f File, b []byte) ([]byte, []string, error) {
+	return t.fs.renderTemplate(f.Source, path.Clean("/"+f.Path), b)
+}
+
+// includesTransformer resolves include directives in html files, see WithIncludes.
+type includesTransformer struct {
+	fs *Fileserver
+}
+
+func (includesTransformer) Name() string {
+	return "includes"
+}
+
+func (t includesTransformer) Match(f File, head []byte) bool {
+	return t.fs.injectFilter.shouldInject(f.Path, head)
+}
+
+func (t includesTransformer) Transform(f File, b []byte) ([]byte, []string, error) {
+	return includes.Resolve(t.fs.masterPath, f.Source, b)
+}
+
+// injectTransformer injects the live reload script into html files, see the injection options.
+type injectTransformer struct {
+	fs *Fileserver
+}
+
+func (injectTransformer) Name() string {
+	return "inject"
+}
+
+func (t injectTransformer) Match(f File, head []byte) bool {
+	return t.fs.injectFilter.shouldInject(f.Path, head)
+}
+
+func (t injectTransformer) Transform(f File, b []byte) ([]byte, []string, error) {
+	injected, err := t.fs.liveReload.Inject(b)
+	if err != nil {
+		// the file is still mirrored, it just won't live reload
+		return nil, nil, fmt.Errorf("failed to inject delta-streamer script, page will not live reload: %v", err)
+	}
+	return injected, nil, nil
+}
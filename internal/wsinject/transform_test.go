@@ -0,0 +1,160 @@
+package wsinject
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pchchv/sws/internal/events"
+)
+
+// funcTransformer is a Transformer of the files with contentType.
+type funcTransformer struct {
+	name        string
+	contentType string
+	transform   func(f File, b []byte) ([]byte, []string, error)
+}
+
+func (t funcTransformer) Name() string {
+	return t.name
+}
+
+func (t funcTransformer) Match(f File, head []byte) bool {
+	return strings.HasPrefix(f.ContentType, t.contentType)
+}
+
+func (t funcTransformer) Transform(f File, b []byte) ([]byte, []string, error) {
+	return t.transform(f, b)
+}
+
+// keyedTransformer is a funcTransformer with a key.
+type keyedTransformer struct {
+	funcTransformer
+	key string
+}
+
+func (t keyedTransformer) Key() string {
+	return t.key
+}
+
+func Test_transformers(t *testing.T) {
+	setup := func(t *testing.T, opts ...Option) (*Fileserver, string) {
+		t.Helper()
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "index.html"), []byte(mockHtml), 0o644)
+		os.WriteFile(filepath.Join(root, "style.css"), []byte("body { color: red; }"), 0o644)
+		os.WriteFile(filepath.Join(root, "vars.css"), []byte("--red: red;"), 0o644)
+		fs := newTestFileServer(t, opts...)
+		if _, err := fs.Setup(root); err != nil {
+			t.Fatalf("failed to setup: %v", err)
+		}
+		t.Cleanup(func() { fs.Close() })
+		return fs, root
+	}
+
+	t.Run("it should run custom transformers before the script injection", func(t *testing.T) {
+		var sawScript bool
+		fs, _ := setup(t, WithTransformer(funcTransformer{
+			name:        "title",
+			contentType: "text/html",
+			transform: func(f File, b []byte) ([]byte, []string, error) {
+				sawScript = bytes.Contains(b, []byte("delta-streamer.js"))
+				return bytes.Replace(b, []byte("<head>"), []byte("<head><title>transformed</title>"), 1), nil, nil
+			},
+		}))
+
+		b, _ := os.ReadFile(filepath.Join(fs.mirrorPath, "index.html"))
+		if !strings.Contains(string(b), "<title>transformed</title>") || !strings.Contains(string(b), "delta-streamer.js") {
+			t.Fatalf("expected mirrored page to be transformed and injected, got: %v", string(b))
+		}
+
+		if sawScript {
+			t.Fatal("expected the custom transformer to run before the script is injected")
+		}
+	})
+
+	t.Run("it should match files by content type and track their dependencies", func(t *testing.T) {
+		fs, root := setup(t, WithTransformer(funcTransformer{
+			name:        "css",
+			contentType: "text/css",
+			transform: func(f File, b []byte) ([]byte, []string, error) {
+				if f.Path != "/style.css" {
+					return b, nil, nil
+				}
+				vars := filepath.Join(filepath.Dir(f.Source), "vars.css")
+				v, err := os.ReadFile(vars)
+				return append(v, b...), []string{vars}, err
+			},
+		}))
+
+		b, _ := os.ReadFile(filepath.Join(fs.mirrorPath, "style.css"))
+		if want := "--red: red;body { color: red; }"; string(b) != want {
+			t.Fatalf("expected: '%v', got: '%v'", want, string(b))
+		}
+
+		if !fs.dependents[filepath.Join(root, "vars.css")][filepath.Join(root, "style.css")] {
+			t.Fatalf("expected style.css to depend on vars.css, got: %v", fs.dependents)
+		}
+	})
+
+	t.Run("it should attribute errors to the transformer and keep the content", func(t *testing.T) {
+		var buf bytes.Buffer
+		events.Enable(&buf)
+		t.Cleanup(events.Disable)
+		fs, _ := setup(t, WithTransformer(funcTransformer{
+			name:        "broken",
+			contentType: "text/css",
+			transform: func(f File, b []byte) ([]byte, []string, error) {
+				return nil, nil, errors.New("syntax error")
+			},
+		}))
+
+		b, _ := os.ReadFile(filepath.Join(fs.mirrorPath, "style.css"))
+		if string(b) != "body { color: red; }" {
+			t.Fatalf("expected the content to be left as it was, got: '%v'", string(b))
+		}
+
+		want := "transformer broken failed on: '/style.css', err: syntax error"
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected an error event containing: '%v', got: %v", want, buf.String())
+		}
+	})
+}
+
+func Test_manifestKey(t *testing.T) {
+	key := func(t *testing.T, tr Transformer) string {
+		t.Helper()
+		return newTestFileServer(t, WithTransformer(tr)).manifestKey()
+	}
+
+	t.Run("it should change with the key of a transformer", func(t *testing.T) {
+		v1 := keyedTransformer{funcTransformer: funcTransformer{name: "css"}, key: "v1"}
+		v2 := keyedTransformer{funcTransformer: funcTransformer{name: "css"}, key: "v2"}
+		if key(t, v1) == key(t, v2) {
+			t.Fatal("expected the manifest key to change with the key of the transformer")
+		}
+
+		if key(t, v1) != key(t, v1) {
+			t.Fatal("expected the manifest key to be stable for the same key")
+		}
+	})
+
+	t.Run("it should change with the name of a transformer without key", func(t *testing.T) {
+		if key(t, funcTransformer{name: "a"}) == key(t, funcTransformer{name: "b"}) {
+			t.Fatal("expected the manifest key to change with the name of the transformer")
+		}
+	})
+}
+
+func Test_TransformError(t *testing.T) {
+	t.Run("it should unwrap to the error of the transformer", func(t *testing.T) {
+		cause := errors.New("syntax error")
+		var err error = &TransformError{Transformer: "css", Path: "/style.css", Err: cause}
+		if !errors.Is(err, cause) {
+			t.Fatalf("expected error to wrap: %v, got: %v", cause, err)
+		}
+	})
+}
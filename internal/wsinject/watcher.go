@@ -0,0 +1,235 @@
+package wsinject
+
+import (
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pchchv/sws/helpers/ancli"
+)
+
+// DefaultPollInterval is the interval at which the polling watcher scans for changes.
+const DefaultPollInterval = 500 * time.Millisecond
+
+// watcher reports changes to the files within the added directories, not recursively.
+type watcher interface {
+	Add(dir string) error
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	Close() error
+}
+
+// notifyWatcher is the fsnotify based watcher.
+type notifyWatcher struct {
+	*fsnotify.Watcher
+}
+
+func (nw notifyWatcher) Events() <-chan fsnotify.Event {
+	return nw.Watcher.Events
+}
+
+func (nw notifyWatcher) Errors() <-chan error {
+	return nw.Watcher.Errors
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+	hash    uint64
+}
+
+// pollWatcher detects changes by scanning the added directories at an interval and
+// comparing modification time, size and, optionally, a hash of the content of every file.
+// It emits the same events as fsnotify does: Create for new files, followed by Write for
+// files which are not directories, Write for changed files and Remove for removed ones.
+// It works on filesystems without change notifications, such as network mounts.
+type pollWatcher struct {
+	interval  time.Duration
+	hash      bool
+	events    chan fsnotify.Event
+	errors    chan error
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	dirs      map[string]map[string]fileState
+}
+
+func newPollWatcher(interval time.Duration, hash bool) *pollWatcher {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	pw := &pollWatcher{
+		interval: interval,
+		hash:     hash,
+		events:   make(chan fsnotify.Event),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+		dirs:     map[string]map[string]fileState{},
+	}
+	go pw.run()
+	return pw
+}
+
+func (pw *pollWatcher) Add(dir string) error {
+	state, err := pw.scan(dir)
+	if err != nil {
+		return err
+	}
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.dirs[dir] = state
+	return nil
+}
+
+func (pw *pollWatcher) Events() <-chan fsnotify.Event {
+	return pw.events
+}
+
+// Errors never receives, failing scans are logged and retried on the next interval instead.
+func (pw *pollWatcher) Errors() <-chan error {
+	return pw.errors
+}
+
+func (pw *pollWatcher) Close() error {
+	pw.closeOnce.Do(func() {
+		close(pw.done)
+	})
+	return nil
+}
+
+func (pw *pollWatcher) run() {
+	ticker := time.NewTicker(pw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pw.done:
+			return
+		case <-ticker.C:
+			if !pw.poll() {
+				return
+			}
+		}
+	}
+}
+
+// poll scans all directories once and emits the changes.
+// It returns false if the watcher was closed meanwhile.
+func (pw *pollWatcher) poll() bool {
+	pw.mu.Lock()
+	dirs := make(map[string]map[string]fileState, len(pw.dirs))
+	for dir, state := range pw.dirs {
+		dirs[dir] = state
+	}
+	pw.mu.Unlock()
+
+	for dir, prev := range dirs {
+		cur, err := pw.scan(dir)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				ancli.Warn("failed to poll directory", "path", dir, "err", err)
+				continue
+			}
+			// as fsnotify, stop watching removed directories
+			cur = nil
+			pw.mu.Lock()
+			delete(pw.dirs, dir)
+			pw.mu.Unlock()
+		}
+
+		for name, state := range cur {
+			p := filepath.Join(dir, name)
+			prevState, existed := prev[name]
+			switch {
+			case !existed:
+				if !pw.send(fsnotify.Event{Name: p, Op: fsnotify.Create}) {
+					return false
+				}
+				if !state.isDir && !pw.send(fsnotify.Event{Name: p, Op: fsnotify.Write}) {
+					return false
+				}
+			case !state.isDir && state != prevState:
+				if !pw.send(fsnotify.Event{Name: p, Op: fsnotify.Write}) {
+					return false
+				}
+			}
+		}
+
+		for name := range prev {
+			if _, exists := cur[name]; !exists {
+				if !pw.send(fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Remove}) {
+					return false
+				}
+			}
+		}
+
+		if cur != nil {
+			pw.mu.Lock()
+			if _, watched := pw.dirs[dir]; watched {
+				pw.dirs[dir] = cur
+			}
+			pw.mu.Unlock()
+		}
+	}
+	return true
+}
+
+func (pw *pollWatcher) send(ev fsnotify.Event) bool {
+	select {
+	case pw.events <- ev:
+		return true
+	case <-pw.done:
+		return false
+	}
+}
+
+func (pw *pollWatcher) scan(dir string) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]fileState, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			// removed since reading the directory
+			continue
+		}
+
+		fs := fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			isDir:   info.IsDir(),
+		}
+		if pw.hash && !fs.isDir {
+			fs.hash, err = hashFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				continue
+			}
+		}
+		state[e.Name()] = fs
+	}
+	return state, nil
+}
+
+func hashFile(p string) (uint64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h := fnv.New64a()
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
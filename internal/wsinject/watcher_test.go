@@ -0,0 +1,100 @@
+package wsinject
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func Test_pollWatcher(t *testing.T) {
+	setup := func(t *testing.T, hash bool) (*pollWatcher, string) {
+		t.Helper()
+		dir := t.TempDir()
+		os.WriteFile(path.Join(dir, "existing.html"), []byte("existing"), 0o644)
+		pw := newPollWatcher(10*time.Millisecond, hash)
+		t.Cleanup(func() { pw.Close() })
+		if err := pw.Add(dir); err != nil {
+			t.Fatalf("failed to add dir: %v", err)
+		}
+		return pw, dir
+	}
+
+	await := func(t *testing.T, pw *pollWatcher, want fsnotify.Event) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case got := <-pw.Events():
+				if got == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("expected event: %v within time", want)
+			}
+		}
+	}
+
+	t.Run("it should emit create and write on new files", func(t *testing.T) {
+		pw, dir := setup(t, false)
+		p := path.Join(dir, "new.html")
+		os.WriteFile(p, []byte("new"), 0o644)
+		await(t, pw, fsnotify.Event{Name: p, Op: fsnotify.Create})
+		await(t, pw, fsnotify.Event{Name: p, Op: fsnotify.Write})
+	})
+
+	t.Run("it should emit write on changed files", func(t *testing.T) {
+		pw, dir := setup(t, false)
+		p := path.Join(dir, "existing.html")
+		os.WriteFile(p, []byte("changed content"), 0o644)
+		await(t, pw, fsnotify.Event{Name: p, Op: fsnotify.Write})
+	})
+
+	t.Run("it should emit remove on removed files", func(t *testing.T) {
+		pw, dir := setup(t, false)
+		p := path.Join(dir, "existing.html")
+		os.Remove(p)
+		await(t, pw, fsnotify.Event{Name: p, Op: fsnotify.Remove})
+	})
+
+	t.Run("it should detect changes with unchanged modification time and size by hash", func(t *testing.T) {
+		pw, dir := setup(t, true)
+		p := path.Join(dir, "existing.html")
+		info, _ := os.Stat(p)
+		os.WriteFile(p, []byte("EXISTING"), 0o644)
+		os.Chtimes(p, info.ModTime(), info.ModTime())
+		await(t, pw, fsnotify.Event{Name: p, Op: fsnotify.Write})
+	})
+}
+
+func Test_WithPolling(t *testing.T) {
+	root := t.TempDir()
+	testFile := path.Join(root, "index.html")
+	os.WriteFile(testFile, []byte(mockHtml), 0o644)
+	fs := newTestFileServer(t, WithPolling(), WithPollInterval(10*time.Millisecond, false))
+	if _, err := fs.Setup(root); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+
+	if _, ok := fs.watcher.(*pollWatcher); !ok {
+		t.Fatalf("expected polling watcher, got: %T", fs.watcher)
+	}
+
+	refreshChan := make(chan string)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	t.Cleanup(cancel)
+	go fs.Watch(ctx, notifyTo(ctx, refreshChan))
+
+	os.WriteFile(testFile, []byte("changes!"), 0o644)
+	select {
+	case got := <-refreshChan:
+		if got != "/index.html" {
+			t.Fatalf("expected reload event to be /index.html, but got %v", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("failed to receive refresh within time")
+	}
+}
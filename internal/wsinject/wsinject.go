@@ -1,93 +1,165 @@
 package wsinject
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
-	"net/http"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/internal/events"
+	"github.com/pchchv/sws/livereload"
 )
 
-const deltaStreamer = `<!-- This script has been injected by sws and allows hot reloads -->
-<script type="module" src="delta-streamer.js"></script>`
-
-var ErrNoHeaderTagFound = errors.New("no header tag found")
-
+// Fileserver mirrors the served directory with the live reload client injected into
+// html files, and reports changes to the mirrored files to the LiveReload.
 type Fileserver struct {
+	liveReload            *livereload.LiveReload
+	notify                func(urlPath string)
 	masterPath            string
+	injectFilter          injectFilter
 	mirrorPath            string
-	forceReload           bool
-	wsPort                int
-	wsPath                string
-	watcher               *fsnotify.Watcher
-	pageReloadChan        chan string
-	wsDispatcher          sync.Map
-	wsDispatcherStarted   *bool
-	wsDispatcherStartedMu *sync.Mutex
-}
-
-func NewFileServer(wsPort int, wsPath string, forceReload bool) *Fileserver {
-	mirrorDir, err := os.MkdirTemp("", "sws_*")
-	if err != nil {
-		panic(err)
+	watcher               watcher
+	watchedDirs           []string
+	followSymlinks        bool
+	stats                 mirrorStats
+	persistentMirror      bool
+	persistentMirrorCache string
+	manifest              *manifest
+	closeOnce             sync.Once
+	includes              bool
+	templates             *templates
+	// transformers are the custom transformers, see WithTransformer
+	transformers []Transformer
+	poll         bool
+	pollInterval time.Duration
+	pollHash     bool
+	// dirAliases maps the real paths of watched directories to their paths below the served directory
+	dirAliases map[string][]string
+	// dependents maps included files to the files including them
+	dependents map[string]map[string]bool
+	// includesOf maps files to the files they include
+	includesOf map[string][]string
+}
+
+// Option configures optional behaviour of the Fileserver.
+type Option func(*Fileserver)
+
+// WithPolling detects file changes by scanning the served directory at an interval,
+// instead of with filesystem notifications, which network filesystems and some container
+// mounts lack. Polling is also used if filesystem notifications fail to set up.
+func WithPolling() Option {
+	return func(fs *Fileserver) {
+		fs.poll = true
 	}
+}
 
-	started := false
-	return &Fileserver{
-		mirrorPath:            mirrorDir,
-		wsPort:                wsPort,
-		wsPath:                wsPath,
-		forceReload:           forceReload,
-		pageReloadChan:        make(chan string),
-		wsDispatcher:          sync.Map{},
-		wsDispatcherStarted:   &started,
-		wsDispatcherStartedMu: &sync.Mutex{},
+// WithPollInterval configures the interval of polling. If hash is set, file contents
+// are compared as well as modification times and sizes.
+func WithPollInterval(interval time.Duration, hash bool) Option {
+	return func(fs *Fileserver) {
+		fs.pollInterval = interval
+		fs.pollHash = hash
 	}
 }
 
-func (fs *Fileserver) Setup(pathToMaster string) (string, error) {
-	ancli.PrintfNotice("mirroring root: '%v'", pathToMaster)
+// WithPersistentMirror keeps the mirror in a per project directory within cacheDir,
+// or the user cache directory if empty, instead of a temporary directory.
+// A manifest of content hashes is kept next to it, so that restarts only rewrite changed files.
+func WithPersistentMirror(cacheDir string) Option {
+	return func(fs *Fileserver) {
+		fs.persistentMirror = true
+		fs.persistentMirrorCache = cacheDir
+	}
+}
+
+// NewFileServer returns a Fileserver which injects and serves the client of lr.
+func NewFileServer(lr *livereload.LiveReload, opts ...Option) *Fileserver {
+	fs := &Fileserver{
+		liveReload:   lr,
+		injectFilter: injectFilter{extensions: DefaultInjectExtensions},
+		pollInterval: DefaultPollInterval,
+		includes:     true,
+	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+	return fs
+}
+
+func (fs *Fileserver) Setup(pathToMaster string) (_ string, err error) {
+	ancli.Notice("mirroring root", "path", pathToMaster)
+	start := time.Now()
 	fs.masterPath = pathToMaster
-	watcher, err := fsnotify.NewWatcher()
-	fs.watcher = watcher
-	if err != nil {
-		return "", fmt.Errorf("failed to create fsnotify watcher: %e", err)
+	if err := fs.setupMirror(pathToMaster); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			fs.Close()
+		}
+	}()
+
+	if fs.poll {
+		if err := fs.usePolling(); err != nil {
+			return "", err
+		}
+	} else if w, notifyErr := fsnotify.NewWatcher(); notifyErr != nil {
+		ancli.Warn("failed to create fsnotify watcher, falling back to polling", "err", notifyErr)
+		if err := fs.usePolling(); err != nil {
+			return "", err
+		}
+	} else {
+		fs.watcher = notifyWatcher{w}
 	}
 
-	if err = wsInjectMaster(pathToMaster, fs.mirrorMaker); err != nil {
+	if fs.followSymlinks {
+		if err := walkFollowingSymlinks(pathToMaster, fs.mirrorMaker); err != nil {
+			return "", fmt.Errorf("failed to create websocket injected mirror: %v", err)
+		}
+	} else if err := wsInjectMaster(pathToMaster, fs.mirrorMaker); err != nil {
 		return "", fmt.Errorf("failed to create websocket injected mirror: %e", err)
 	}
 
-	if err = fs.writeDeltaStreamerScript(); err != nil {
+	if err := fs.writeDeltaStreamerScript(); err != nil {
 		return "", fmt.Errorf("failed to write delta streamer file: %e", err)
 	}
 
+	fs.manifest.removeUnseen(fs.mirrorPath)
+	if err := fs.manifest.save(); err != nil {
+		ancli.Warn("failed to save mirror manifest", "err", err)
+	}
+
+	fs.reportStats(time.Since(start))
 	return fs.mirrorPath, nil
 }
 
-// Start starts listening to file events,
-// update mirror and stream notifications on which files to update.
-func (fs *Fileserver) Start(ctx context.Context) error {
+// Watch listens to file events, updates the mirror and notifies of the url paths
+// of updated files, until ctx is done. It implements livereload.Watcher.
+func (fs *Fileserver) Watch(ctx context.Context, notify func(urlPath string)) error {
+	fs.notify = notify
+	defer fs.watcher.Close()
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
-		case fsEv, ok := <-fs.watcher.Events:
+		case fsEv, ok := <-fs.watcher.Events():
 			if !ok {
 				return errors.New("fsnotify watcher event channel closed")
 			}
 			fs.handleFileEvent(fsEv)
-		case fsErr, ok := <-fs.watcher.Errors:
+		case fsErr, ok := <-fs.watcher.Errors():
 			if !ok {
 				return errors.New("fsnotify watcher error channel closed")
 			}
@@ -96,6 +168,29 @@ func (fs *Fileserver) Start(ctx context.Context) error {
 	}
 }
 
+// Close stops watching files, saves the manifest of a persistent mirror and removes a temporary mirror.
+func (fs *Fileserver) Close() error {
+	var err error
+	fs.closeOnce.Do(func() {
+		if fs.watcher != nil {
+			fs.watcher.Close()
+		}
+
+		if fs.persistentMirror {
+			err = fs.manifest.save()
+			return
+		}
+
+		if fs.mirrorPath != "" {
+			ancli.Debug("removing temporary mirror", "path", fs.mirrorPath)
+			if err = os.RemoveAll(fs.mirrorPath); err != nil {
+				err = fmt.Errorf("failed to remove temporary mirror: %v", err)
+			}
+		}
+	})
+	return err
+}
+
 func wsInjectMaster(root string, do func(path string, d fs.DirEntry, err error) error) error {
 	if err := filepath.WalkDir(root, do); err != nil {
 		log.Fatalf("Error walking the path %q: %v\n", root, err)
@@ -103,89 +198,128 @@ func wsInjectMaster(root string, do func(path string, d fs.DirEntry, err error)
 	return nil
 }
 
-func injectScript(html []byte, scriptTag string) ([]byte, error) {
-	htmlStr := string(html)
-	// find the location of the closing `</header>` tag
-	idx := strings.Index(htmlStr, "</head>")
-	if idx == -1 {
-		return html, ErrNoHeaderTagFound
+func (fs *Fileserver) writeDeltaStreamerScript() error {
+	scriptPath := path.Join(fs.mirrorPath, fs.liveReload.ScriptPath())
+	if err := os.MkdirAll(path.Dir(scriptPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create script dir: %v", err)
 	}
 
-	var buf bytes.Buffer
-	// write the HTML up to the closing `</head>` tag
-	if _, err := buf.WriteString(htmlStr[:idx]); err != nil {
-		return nil, fmt.Errorf("failed to write pre: %e", err)
+	if err := os.WriteFile(scriptPath, fs.liveReload.Script(), 0o755); err != nil {
+		return fmt.Errorf("failed to write delta-streamer.js: %e", err)
 	}
+	return nil
+}
 
-	if _, err := buf.WriteString(scriptTag); err != nil {
-		return nil, fmt.Errorf("failed to write script tag: %e", err)
+// mirrorFile places the file on origPath into the mirror, injecting the delta-streamer script if
+// it is html. It returns false if the file has the same content as when it was last mirrored.
+func (fs *Fileserver) mirrorFile(origPath string) (bool, error) {
+	start := time.Now()
+	relativePath := strings.Replace(origPath, fs.masterPath, "", -1)
+	outputPath := fs.outputPath(relativePath)
+	mirroredPath := path.Join(fs.mirrorPath, outputPath)
+	f, err := os.Open(origPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file on path: '%v', err: %v", origPath, err)
 	}
+	defer f.Close()
 
-	if _, err := buf.WriteString(htmlStr[idx:]); err != nil {
-		return nil, fmt.Errorf("failed to write post: %e", err)
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat file on path: '%v', err: %v", origPath, err)
 	}
 
-	return buf.Bytes(), nil
-}
-
-func injectWebsocketScript(b []byte) (bool, []byte, error) {
-	var injected bool
-	contentType := http.DetectContentType(b)
-	// only act on html files
-	if !strings.Contains(contentType, "text/html") {
-		return injected, b, nil
+	hash, err := fs.contentHash(filepath.ToSlash(outputPath), origPath, info)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash file on path: '%v', err: %v", origPath, err)
 	}
 
-	injected = true
-	b, err := injectScript(b, deltaStreamer)
-	if err != nil {
-		if !errors.Is(err, ErrNoHeaderTagFound) {
-			return injected, nil, fmt.Errorf("failed to inject script tag: %e", err)
-		} else {
-			injected = false
+	entry := manifestEntry{Hash: hash, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	if prev, unchanged := fs.manifest.unchanged(filepath.ToSlash(outputPath), entry); unchanged {
+		if mirroredInfo, err := os.Stat(mirroredPath); err == nil {
+			// files touched without changing are not hashed again
+			prev.ModTime = entry.ModTime
+			fs.manifest.Files[filepath.ToSlash(outputPath)] = prev
+			fs.trackIncludes(origPath, slices.Collect(maps.Keys(prev.Deps)))
+			// the mode or modification time may have changed without the content
+			if !os.SameFile(info, mirroredInfo) {
+				if err := preserveAttributes(mirroredPath, info); err != nil {
+					return false, err
+				}
+
+				if err := setMirrorModTime(mirroredPath, info, slices.Collect(maps.Keys(prev.Deps))); err != nil {
+					return false, err
+				}
+			}
+			fs.stats.add(placeUnchanged, info.Size())
+			return false, nil
 		}
 	}
 
-	return injected, b, nil
-}
-
-func (fs *Fileserver) writeDeltaStreamerScript() error {
-	err := os.WriteFile(
-		path.Join(fs.mirrorPath, "delta-streamer.js"),
-		[]byte(fmt.Sprintf(deltaStreamerSourceCode, fs.wsPort, fs.wsPath, fs.forceReload)),
-		0o755)
-	if err != nil {
-		return fmt.Errorf("failed to write delta-streamer.js: %e", err)
+	relativePathDir := path.Dir(mirroredPath)
+	if err := os.MkdirAll(relativePathDir, 0o755); err != nil {
+		return false, fmt.Errorf("failed to create relative dir: '%v', error: %v", relativePathDir, err)
 	}
 
-	return nil
-}
-
-func (fs *Fileserver) mirrorFile(origPath string) error {
-	relativePath := strings.Replace(origPath, fs.masterPath, "", -1)
-	fileB, err := os.ReadFile(origPath)
-	if err != nil {
-		return fmt.Errorf("failed to read file on path: '%v', err: %v", origPath, err)
+	// the previous mirrored file may be a hard link to the original, which must not be written to
+	if err := os.Remove(mirroredPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("failed to remove previous mirrored file: %v", err)
 	}
 
-	injected, injectedBytes, err := injectWebsocketScript(fileB)
+	head, err := readHead(f)
 	if err != nil {
-		return fmt.Errorf("failed to inject websocket script: %e", err)
-	} else if injected {
-		ancli.PrintfNotice("injected delta-streamer script loading tag in: '%v'", origPath)
+		return false, fmt.Errorf("failed to read file on path: '%v', err: %v", origPath, err)
 	}
 
-	mirroredPath := path.Join(fs.mirrorPath, relativePath)
-	relativePathDir := path.Dir(mirroredPath)
-	if err = os.MkdirAll(relativePathDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create relative dir: '%v', error: %v", relativePathDir, err)
-	}
+	var placed placement
+	var deps []string
+	pipeline := fs.pipeline()
+	mirrored := file(filepath.ToSlash(outputPath), origPath, head)
+	if matches(pipeline, mirrored, head) {
+		// only files to transform are read fully
+		rest, err := io.ReadAll(f)
+		if err != nil {
+			return false, fmt.Errorf("failed to read file on path: '%v', err: %v", origPath, err)
+		}
 
-	if err = os.WriteFile(mirroredPath, injectedBytes, 0o755); err != nil {
-		return fmt.Errorf("failed to write mirrored file: %e", err)
-	}
+		var applied []string
+		fileB := append(head, rest...)
+		fileB, deps, applied = fs.transform(pipeline, mirrored, fileB)
+		placed = placeWritten
+		if slices.Contains(applied, injectTransformer{}.Name()) {
+			placed = placeInjected
+			ancli.Debug("injected delta-streamer script loading tag", "path", origPath)
+			events.Emit(events.Event{Type: events.Injected, Path: relativePath, File: origPath})
+		}
 
-	return nil
+		if err = os.WriteFile(mirroredPath, fileB, info.Mode().Perm()); err != nil {
+			return false, fmt.Errorf("failed to write mirrored file: %e", err)
+		}
+
+		if err = preserveAttributes(mirroredPath, info); err != nil {
+			return false, err
+		}
+
+		if err = setMirrorModTime(mirroredPath, info, deps); err != nil {
+			return false, err
+		}
+	} else {
+		f.Close()
+		if placed, err = linkOrCopy(origPath, mirroredPath); err != nil {
+			return false, fmt.Errorf("failed to place mirrored file: %v", err)
+		}
+	}
+	fs.trackIncludes(origPath, deps)
+	entry.Deps = hashDeps(deps)
+	fs.stats.add(placed, info.Size())
+	fs.manifest.Files[filepath.ToSlash(outputPath)] = entry
+
+	events.Emit(events.Event{
+		Type:       events.Mirrored,
+		Path:       relativePath,
+		File:       origPath,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	})
+	return true, nil
 }
 
 func (fs *Fileserver) mirrorMaker(p string, info os.DirEntry, err error) error {
@@ -194,24 +328,114 @@ func (fs *Fileserver) mirrorMaker(p string, info os.DirEntry, err error) error {
 	}
 
 	if info.IsDir() {
-		if err = fs.watcher.Add(p); err != nil {
-			return fmt.Errorf("failed to add recursive path: %e", err)
+		return fs.watch(p)
+	}
+
+	_, err = fs.mirrorFile(p)
+	return err
+}
+
+// usePolling replaces the watcher with a polling watcher, which watches all directories watched so far.
+func (fs *Fileserver) usePolling() error {
+	if fs.watcher != nil {
+		fs.watcher.Close()
+	}
+	ancli.Notice("watching for file changes by polling", "interval", fs.pollInterval, "hash", fs.pollHash)
+	fs.poll = true
+	fs.watcher = newPollWatcher(fs.pollInterval, fs.pollHash)
+	for _, dir := range fs.watchedDirs {
+		if err := fs.watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to poll path: '%v', err: %v", dir, err)
 		}
+	}
+	return nil
+}
+
+// watch adds the directory to the watcher. If fsnotify fails to watch it,
+// for example due to the inotify watch limit, polling is used instead.
+func (fs *Fileserver) watch(dir string) error {
+	if fs.followSymlinks {
+		// symlinked directories are watched on their real path, once
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: '%v', err: %v", dir, err)
+		}
+
+		if fs.dirAliases == nil {
+			fs.dirAliases = map[string][]string{}
+		}
+		_, watched := fs.dirAliases[real]
+		fs.dirAliases[real] = append(fs.dirAliases[real], dir)
+		if watched {
+			return nil
+		}
+		dir = real
+	}
+
+	fs.watchedDirs = append(fs.watchedDirs, dir)
+	err := fs.watcher.Add(dir)
+	if err == nil {
 		return nil
 	}
 
-	return fs.mirrorFile(p)
+	if fs.poll {
+		return fmt.Errorf("failed to poll path: '%v', err: %v", dir, err)
+	}
+	ancli.Warn("failed to watch path with fsnotify, falling back to polling", "path", dir, "err", err)
+	return fs.usePolling()
+}
+
+// reportStats logs how the files were mirrored, how long it took and the peak memory use.
+func (fs *Fileserver) reportStats(duration time.Duration) {
+	attrs := []any{
+		"files", fs.stats.files,
+		"bytes", fs.stats.bytes,
+		"duration", duration,
+	}
+	for _, p := range []placement{placeUnchanged, placeInjected, placeWritten, placeLinked, placeReflinked, placeCopied} {
+		if n := fs.stats.placed[p]; n > 0 {
+			attrs = append(attrs, string(p), n)
+		}
+	}
+	if peak, ok := peakMemory(); ok {
+		attrs = append(attrs, "peakMemory", peak)
+	}
+	ancli.OK("mirrored root", attrs...)
 }
 
 func (fs *Fileserver) notifyPageUpdate(fileName string) {
 	// make filename relative idempotently
-	fs.pageReloadChan <- strings.Replace(fileName, fs.masterPath, "", -1)
+	fs.notify(fs.outputPath(strings.Replace(fileName, fs.masterPath, "", -1)))
 }
 
 func (fs *Fileserver) handleFileEvent(fsEv fsnotify.Event) {
-	if fsEv.Has(fsnotify.Write) {
-		ancli.PrintfNotice("noticed file write in orig file: '%s'", fsEv.Name)
-		fs.mirrorFile(fsEv.Name)
-		fs.notifyPageUpdate(fsEv.Name)
+	if !fsEv.Has(fsnotify.Write) {
+		return
+	}
+
+	for _, name := range fs.logicalPaths(fsEv.Name) {
+		ancli.Notice("noticed file write in orig file", "path", name)
+		relativePath := strings.Replace(name, fs.masterPath, "", -1)
+		events.Emit(events.Event{Type: events.FileChanged, Path: relativePath, File: name})
+		start := time.Now()
+		changed, err := fs.mirrorFile(name)
+		if err != nil {
+			ancli.Err("failed to mirror file", "path", name, "err", err)
+			events.Err(err, relativePath, name)
+			continue
+		}
+
+		if !changed {
+			ancli.Debug("file content unchanged, skipping reload", "path", name)
+			continue
+		}
+		ancli.Debug("mirrored file", "path", name, "duration", time.Since(start))
+		fs.notifyPageUpdate(name)
+		if fs.templates != nil && fs.templates.isTemplateInput(name) && len(fs.dependents[name]) == 0 {
+			// new layouts and data files are not tracked as dependencies yet
+			fs.updateTemplates()
+		} else {
+			fs.updateDependents(name)
+		}
 	}
 }
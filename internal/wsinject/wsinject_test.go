@@ -1,6 +1,7 @@
 package wsinject
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/pchchv/sws/helpers/ancli"
+	"github.com/pchchv/sws/livereload"
 )
 
 const mockHtml = `<!DOCTYPE html>
@@ -45,6 +47,25 @@ func (tfs *testFileSystem) addRootFile(t *testing.T, suffix string) string {
 	return path
 }
 
+func newTestFileServer(t *testing.T, opts ...Option) *Fileserver {
+	t.Helper()
+	lr, err := livereload.New()
+	if err != nil {
+		t.Fatalf("failed to create live reload: %v", err)
+	}
+	return NewFileServer(lr, opts...)
+}
+
+// notifyTo returns a notify func which sends the url paths on c, until ctx is done.
+func notifyTo(ctx context.Context, c chan string) func(string) {
+	return func(urlPath string) {
+		select {
+		case c <- urlPath:
+		case <-ctx.Done():
+		}
+	}
+}
+
 func Test_walkDir(t *testing.T) {
 	t.Run("it should visit every file ", func(t *testing.T) {
 		var got []string
@@ -93,7 +114,7 @@ func Test_Setup(t *testing.T) {
 
 	nestedFile := path.Join(nestedDir, "nested.html")
 	os.WriteFile(nestedFile, []byte(mockHtml), 0o777)
-	fs := NewFileServer(8080, "/delta-streamer-ws.js", false)
+	fs := newTestFileServer(t)
 	if _, err := fs.Setup(tmpDir); err != nil {
 		t.Fatalf("failed to setup: %v", err)
 	}
@@ -133,7 +154,7 @@ func Test_Setup(t *testing.T) {
 	})
 }
 
-func Test_Start(t *testing.T) {
+func Test_Watch(t *testing.T) {
 	setup := func(t *testing.T) (*Fileserver, testFileSystem) {
 		t.Helper()
 		tmpDir := t.TempDir()
@@ -142,7 +163,7 @@ func Test_Start(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create temp dir: %v", err)
 		}
-		return NewFileServer(8080, "/delta-streamer-ws.js", false), testFileSystem{
+		return newTestFileServer(t), testFileSystem{
 			root:      tmpDir,
 			nestedDir: nestedDir,
 		}
@@ -160,7 +181,7 @@ func Test_Start(t *testing.T) {
 
 		done := make(chan struct{})
 		go func() {
-			fs.Start(timeoutCtx)
+			fs.Watch(timeoutCtx, func(string) {})
 			close(done)
 		}()
 
@@ -180,14 +201,13 @@ func Test_Start(t *testing.T) {
 			testFileSystem.addRootFile(t, "")
 			fs.Setup(testFileSystem.root)
 			refreshChan := make(chan string)
-			fs.registerWs("mock", refreshChan)
 			timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second)
 			t.Cleanup(cancel)
 			earlyFail := make(chan error, 1)
 			awaitFsStart := make(chan struct{})
 			go func() {
 				close(awaitFsStart)
-				err := fs.Start(timeoutCtx)
+				err := fs.Watch(timeoutCtx, notifyTo(timeoutCtx, refreshChan))
 				if err != nil {
 					earlyFail <- err
 				}
@@ -234,3 +254,25 @@ func Test_Start(t *testing.T) {
 		})
 	})
 }
+
+func Test_writeDeltaStreamerScript(t *testing.T) {
+	lr, err := livereload.New(livereload.WithScriptPath("/_sws/client.js"))
+	if err != nil {
+		t.Fatalf("failed to create live reload: %v", err)
+	}
+
+	fs := NewFileServer(lr)
+	if _, err := fs.Setup(t.TempDir()); err != nil {
+		t.Fatalf("failed to setup: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+
+	b, err := os.ReadFile(path.Join(fs.mirrorPath, "_sws", "client.js"))
+	if err != nil {
+		t.Fatalf("failed to read client script: %v", err)
+	}
+
+	if !bytes.Equal(b, lr.Script()) {
+		t.Fatal("expected the mirrored client script to equal the live reload script")
+	}
+}
@@ -0,0 +1,714 @@
+package livereload
+
+// clientSource is the script of the client, formatted with the settings of the LiveReload by renderScript.
+const clientSource = `/**
+* This file has been injected by the sws web development
+* hot reload tool. 
+*/
+
+// Url path prefixes of files which always trigger a reload, set using string interpolation
+const alwaysReloadPrefixes = %s;
+// Token which authenticates the websocket connection, if the server requires it
+const wsToken = %s;
+// Morph the DOM to match changed html instead of reloading, set using string interpolation from the -morph flag
+const morph = %v;
+// Mirror scrolling, navigation, clicks and form input of other browsers, set using string interpolation from the -sync flag
+const sync = %v;
+// Reload on any file change, set using string interpolation from the -forceReload flag
+const forceReload = %v;
+// Port and path of the websocket, the port is 0 if it's served on the host of the page
+const wsPort = %v;
+const wsPath = %s;
+
+// Pages opt out of preserving their state across reloads with <meta name="sws-preserve-state" content="off">
+const stateKey = 'sws:state:' + window.location.pathname;
+// Snapshots older than this are stale, as they weren't restored by the reload they were taken for
+const stateMaxAgeMs = 30000;
+
+function preserveStateEnabled() {
+  const meta = document.querySelector('meta[name="sws-preserve-state"]');
+  return !meta || !['off', 'false', 'no'].includes(meta.content.trim().toLowerCase());
+}
+
+// elementPath returns a selector which finds the element again after the reload
+function elementPath(el) {
+  const parts = [];
+  while (el && el.nodeType === Node.ELEMENT_NODE && el !== document.documentElement) {
+    if (el.id) {
+      parts.unshift('#' + CSS.escape(el.id));
+      break;
+    }
+    let index = 1;
+    for (let sibling = el.previousElementSibling; sibling; sibling = sibling.previousElementSibling) {
+      if (sibling.tagName === el.tagName) {
+        index++;
+      }
+    }
+    parts.unshift(el.tagName.toLowerCase() + ':nth-of-type(' + index + ')');
+    el = el.parentElement;
+  }
+  return parts.join(' > ');
+}
+
+function snapshotState() {
+  const state = {
+    time: Date.now(),
+    scroll: [window.scrollX, window.scrollY],
+    containers: [],
+    fields: [],
+    focus: null,
+  };
+
+  document.querySelectorAll('body *').forEach((el) => {
+    if (el.scrollTop !== 0 || el.scrollLeft !== 0) {
+      state.containers.push({ path: elementPath(el), left: el.scrollLeft, top: el.scrollTop });
+    }
+  });
+
+  document.querySelectorAll('input, textarea, select').forEach((el) => {
+    // never store secrets, and files can't be restored
+    if (['password', 'file', 'hidden'].includes(el.type)) {
+      return;
+    }
+    const field = { path: elementPath(el) };
+    if (el.type === 'checkbox' || el.type === 'radio') {
+      field.checked = el.checked;
+    } else if (el.tagName === 'SELECT') {
+      field.selected = Array.from(el.options).map((option) => option.selected);
+    } else {
+      field.value = el.value;
+    }
+    state.fields.push(field);
+  });
+
+  const active = document.activeElement;
+  if (active && active !== document.body && active !== document.documentElement) {
+    state.focus = { path: elementPath(active) };
+    if (typeof active.selectionStart === 'number') {
+      state.focus.selection = [active.selectionStart, active.selectionEnd];
+    }
+  }
+
+  try {
+    sessionStorage.setItem(stateKey, JSON.stringify(state));
+  } catch (e) {
+    console.warn('sws: failed to store page state:', e);
+  }
+}
+
+function restoreState() {
+  let state;
+  try {
+    state = JSON.parse(sessionStorage.getItem(stateKey));
+    sessionStorage.removeItem(stateKey);
+  } catch (e) {
+    return;
+  }
+  if (!state || Date.now() - state.time > stateMaxAgeMs || !preserveStateEnabled()) {
+    return;
+  }
+
+  const find = (path) => {
+    try {
+      return path ? document.querySelector(path) : null;
+    } catch (e) {
+      return null;
+    }
+  };
+
+  state.fields.forEach((field) => {
+    const el = find(field.path);
+    if (!el) {
+      return;
+    }
+    if ('checked' in field) {
+      el.checked = field.checked;
+    } else if ('selected' in field && el.options) {
+      Array.from(el.options).forEach((option, i) => {
+        option.selected = !!field.selected[i];
+      });
+    } else if ('value' in field && 'value' in el) {
+      el.value = field.value;
+    }
+  });
+
+  state.containers.forEach((container) => {
+    const el = find(container.path);
+    if (el) {
+      el.scrollTo(container.left, container.top);
+    }
+  });
+  window.scrollTo(state.scroll[0], state.scroll[1]);
+
+  if (state.focus) {
+    const el = find(state.focus.path);
+    if (el && typeof el.focus === 'function') {
+      el.focus({ preventScroll: true });
+      if (state.focus.selection && typeof el.setSelectionRange === 'function') {
+        try {
+          el.setSelectionRange(state.focus.selection[0], state.focus.selection[1]);
+        } catch (e) {
+          // not all input types support selections
+        }
+      }
+    }
+  }
+}
+
+// reload reloads the page, keeping scroll positions, focus and form values unless the page opted out
+function reload() {
+  if (preserveStateEnabled()) {
+    snapshotState();
+  }
+  location.reload();
+}
+
+if (sessionStorage.getItem(stateKey) !== null) {
+  // the browser's own scroll restoration would race the restored positions
+  history.scrollRestoration = 'manual';
+  if (document.readyState === 'complete') {
+    restoreState();
+  } else {
+    // restored once images have loaded, so that scroll positions are reachable
+    window.addEventListener('load', restoreState, { once: true });
+  }
+}
+
+const imageExtensions = ['.apng', '.avif', '.bmp', '.gif', '.ico', '.jpeg', '.jpg', '.png', '.svg', '.webp'];
+
+function isImage(path) {
+  const lower = path.toLowerCase();
+  return imageExtensions.some((ext) => lower.endsWith(ext));
+}
+
+// refersTo reports if url, relative to base, points at the file on path of this server
+function refersTo(url, base, path) {
+  try {
+    const u = new URL(url, base);
+    return u.origin === window.location.origin && decodeURIComponent(u.pathname) === path;
+  } catch (e) {
+    return false;
+  }
+}
+
+// bust returns url with a query which makes the browser fetch it again
+function bust(url, base, version) {
+  const u = new URL(url, base);
+  u.searchParams.set('sws', version);
+  return u.href;
+}
+
+function swapSrcset(srcset, path, version) {
+  let swapped = false;
+  const candidates = srcset.split(',').map((candidate) => {
+    const parts = candidate.trim().split(/\s+/);
+    if (parts[0] !== '' && refersTo(parts[0], document.baseURI, path)) {
+      parts[0] = bust(parts[0], document.baseURI, version);
+      swapped = true;
+    }
+    return parts.join(' ');
+  });
+  return swapped ? candidates.join(', ') : null;
+}
+
+const cssUrlRe = /url\(\s*(['"]?)(.*?)\1\s*\)/g;
+
+function swapCssUrls(value, base, path, version) {
+  let swapped = false;
+  const result = value.replace(cssUrlRe, (match, quote, url) => {
+    if (!refersTo(url, base, path)) {
+      return match;
+    }
+    swapped = true;
+    return 'url("' + bust(url, base, version) + '")';
+  });
+  return swapped ? result : null;
+}
+
+function swapStyleRules(rules, base, path, version) {
+  let swapped = 0;
+  for (const rule of rules) {
+    if (rule.style) {
+      for (const property of ['background-image', 'background', 'mask-image', 'list-style-image', 'content']) {
+        const value = rule.style.getPropertyValue(property);
+        const updated = value && swapCssUrls(value, base, path, version);
+        if (updated) {
+          rule.style.setProperty(property, updated, rule.style.getPropertyPriority(property));
+          swapped++;
+        }
+      }
+    }
+    if (rule.cssRules) {
+      swapped += swapStyleRules(rule.cssRules, base, path, version);
+    }
+  }
+  return swapped;
+}
+
+// swapImage updates all references to the image on path in place and returns how many it updated
+function swapImage(path) {
+  const version = Date.now();
+  let swapped = 0;
+
+  document.querySelectorAll('img, source, input[type="image"]').forEach((el) => {
+    const src = el.getAttribute('src');
+    if (src && refersTo(src, document.baseURI, path)) {
+      el.setAttribute('src', bust(src, document.baseURI, version));
+      swapped++;
+    }
+    const srcset = el.getAttribute('srcset');
+    const updated = srcset && swapSrcset(srcset, path, version);
+    if (updated) {
+      el.setAttribute('srcset', updated);
+      swapped++;
+    }
+  });
+
+  const xlink = 'http://www.w3.org/1999/xlink';
+  document.querySelectorAll('use, image').forEach((el) => {
+    for (const [ns, name] of [[null, 'href'], [xlink, 'href']]) {
+      const href = el.getAttributeNS(ns, name);
+      if (href && refersTo(href, document.baseURI, path)) {
+        el.setAttributeNS(ns, ns ? 'xlink:href' : 'href', bust(href, document.baseURI, version));
+        swapped++;
+      }
+    }
+  });
+
+  document.querySelectorAll('[style]').forEach((el) => {
+    const updated = swapCssUrls(el.getAttribute('style'), document.baseURI, path, version);
+    if (updated) {
+      el.setAttribute('style', updated);
+      swapped++;
+    }
+  });
+
+  for (const sheet of document.styleSheets) {
+    try {
+      // urls in stylesheets are relative to the stylesheet
+      swapped += swapStyleRules(sheet.cssRules, sheet.href || document.baseURI, path, version);
+    } catch (e) {
+      // the rules of cross origin stylesheets can't be read
+    }
+  }
+  return swapped;
+}
+
+// scriptsOf lists the scripts of doc, which can't be morphed without running them again
+function scriptsOf(doc) {
+  return Array.from(doc.querySelectorAll('script')).map((script) =>
+    script.getAttribute('src') || script.getAttribute('type') + ':' + script.textContent);
+}
+
+function sameScripts(a, b) {
+  const scriptsA = scriptsOf(a);
+  const scriptsB = scriptsOf(b);
+  return scriptsA.length === scriptsB.length && scriptsA.every((script, i) => script === scriptsB[i]);
+}
+
+function morphAttributes(from, to) {
+  for (const attr of Array.from(from.attributes)) {
+    if (!to.hasAttributeNS(attr.namespaceURI, attr.localName)) {
+      from.removeAttributeNS(attr.namespaceURI, attr.localName);
+    }
+  }
+  for (const attr of Array.from(to.attributes)) {
+    if (from.getAttributeNS(attr.namespaceURI, attr.localName) !== attr.value) {
+      from.setAttributeNS(attr.namespaceURI, attr.name, attr.value);
+    }
+  }
+}
+
+// sameNode reports if from can be morphed into to. Elements with ids only match their id.
+function sameNode(from, to) {
+  if (from.nodeType !== to.nodeType || from.nodeName !== to.nodeName) {
+    return false;
+  }
+  if (from.nodeType === Node.ELEMENT_NODE && (from.id || to.id)) {
+    return from.id === to.id;
+  }
+  return true;
+}
+
+function morphNode(from, to) {
+  if (from.nodeType !== Node.ELEMENT_NODE) {
+    if (from.nodeValue !== to.nodeValue) {
+      from.nodeValue = to.nodeValue;
+    }
+    return;
+  }
+  morphAttributes(from, to);
+  // form fields keep the values entered, as only their attributes and default values change
+  if (from.tagName !== 'SCRIPT') {
+    morphChildren(from, to);
+  }
+}
+
+function morphChildren(from, to) {
+  let current = from.firstChild;
+  for (const next of Array.from(to.childNodes)) {
+    let match = null;
+    if (next.nodeType === Node.ELEMENT_NODE && next.id) {
+      // elements with ids are kept, even if they moved
+      match = Array.from(from.childNodes).find((child) => child.nodeType === Node.ELEMENT_NODE && child.id === next.id) || null;
+    } else if (current && sameNode(current, next)) {
+      match = current;
+    }
+
+    if (!match) {
+      from.insertBefore(document.importNode(next, true), current);
+      continue;
+    }
+
+    if (match === current) {
+      current = current.nextSibling;
+    } else {
+      from.insertBefore(match, current);
+    }
+    morphNode(match, next);
+  }
+
+  while (current) {
+    const stale = current;
+    current = current.nextSibling;
+    stale.remove();
+  }
+}
+
+// morphPage fetches the current page and morphs the live DOM to match it,
+// falling back to a reload if its scripts changed or it fails to fetch
+async function morphPage() {
+  let doc;
+  try {
+    const response = await fetch(window.location.href, { cache: 'no-store', headers: { Accept: 'text/html' } });
+    if (!response.ok || !(response.headers.get('Content-Type') || '').includes('text/html')) {
+      throw new Error('unexpected response: ' + response.status);
+    }
+    doc = new DOMParser().parseFromString(await response.text(), 'text/html');
+  } catch (e) {
+    console.warn('sws: failed to fetch page to morph, reloading:', e);
+    reload();
+    return;
+  }
+
+  if (!sameScripts(document, doc)) {
+    reload();
+    return;
+  }
+  morphAttributes(document.documentElement, doc.documentElement);
+  morphNode(document.head, doc.head);
+  morphNode(document.body, doc.body);
+}
+
+let morphing = Promise.resolve();
+
+// queueMorph morphs the page once previous morphs are done, so that rapid changes apply in order
+function queueMorph() {
+  morphing = morphing.then(morphPage, morphPage);
+}
+
+// defaultAction returns how a change to the file on path is applied, unless page code handles it:
+// 'swap' the image, 'morph' or 'reload' the page, or 'none'
+function defaultAction(path) {
+  const alwaysReload = alwaysReloadPrefixes.some((prefix) => path.startsWith(prefix));
+  // Images are swapped in place, pages which don't reference the image are left as they are
+  if (isImage(path) && !alwaysReload) {
+    return 'swap';
+  }
+
+  // Directories are served by their index
+  let page = decodeURIComponent(window.location.pathname);
+  if (page.endsWith('/')) {
+    page += 'index.html';
+  }
+  // Morph or reload page if it's detected that the current page has been altered
+  const pageChanged = path === page;
+  if (morph && pageChanged) {
+    return 'morph';
+  }
+  if (pageChanged ||
+    // Always reload on js and css files since its difficult to know where these are used
+    path.includes(".js") ||
+    path.includes(".css") ||
+    alwaysReload ||
+    forceReload
+  ) {
+    return 'reload';
+  }
+  return 'none';
+}
+
+let paused = false;
+const pendingUpdates = [];
+
+// handleUpdate dispatches the cancelable sws:update event for the change to the file on path,
+// and applies its default action unless a listener prevented it
+function handleUpdate(path) {
+  if (paused) {
+    if (!pendingUpdates.includes(path)) {
+      pendingUpdates.push(path);
+    }
+    return;
+  }
+
+  const detail = { path: path, action: defaultAction(path) };
+  if (!window.dispatchEvent(new CustomEvent('sws:update', { detail: detail, cancelable: true }))) {
+    return;
+  }
+
+  switch (detail.action) {
+    case 'swap':
+      // pages which don't show the image are left alone, unless every change reloads
+      if (swapImage(path) === 0 && forceReload) {
+        reload();
+      }
+      break;
+    case 'morph':
+      queueMorph();
+      break;
+    case 'reload':
+      reload();
+      break;
+  }
+}
+
+function matchesPath(pattern, path) {
+  if (pattern instanceof RegExp) {
+    return pattern.test(path);
+  }
+  return pattern.endsWith('/') ? path.startsWith(pattern) : path === pattern;
+}
+
+// window.sws is the stable api of this script for page code, documented in the README.
+// Changes to it have to stay backwards compatible.
+window.sws = Object.freeze({
+  version: 1,
+  // on calls handler with the event for each update, and returns a function which removes it.
+  // Calling event.preventDefault() in the handler skips the default action.
+  on(type, handler) {
+    const listener = (event) => handler(event);
+    window.addEventListener('sws:' + type, listener);
+    return () => window.removeEventListener('sws:' + type, listener);
+  },
+  // accept handles changes to the file on path, a url path prefix ending with '/' or a RegExp,
+  // by calling handler with the update instead of applying its default action
+  accept(path, handler) {
+    return this.on('update', (event) => {
+      if (matchesPath(path, event.detail.path)) {
+        event.preventDefault();
+        if (handler) {
+          handler(event.detail);
+        }
+      }
+    });
+  },
+  // pause holds back updates until resume is called
+  pause() {
+    paused = true;
+  },
+  // resume handles the updates held back while paused, once each
+  resume() {
+    paused = false;
+    pendingUpdates.splice(0).forEach(handleUpdate);
+  },
+  get paused() {
+    return paused;
+  },
+  // reload reloads the page, preserving its state unless it opted out
+  reload: reload,
+});
+
+let socket = null;
+// Own scroll events are not reported right after applying a remote scroll position
+let remoteScrollUntil = 0;
+// followedKey holds the url this browser was sent to by another one, so it is not echoed back
+const followedKey = 'sws:followed';
+
+// userNavigated reports if the user navigated to this page, rather than it being reloaded on a
+// change, or opened to follow the navigation of another browser
+function userNavigated() {
+  let followed = null;
+  try {
+    followed = sessionStorage.getItem(followedKey);
+    sessionStorage.removeItem(followedKey);
+  } catch (e) {
+    // without storage, followed navigations are echoed, which the other browsers ignore
+  }
+  if (followed === window.location.pathname + window.location.search + window.location.hash) {
+    return false;
+  }
+  const entries = performance.getEntriesByType ? performance.getEntriesByType('navigation') : [];
+  return entries.length === 0 || entries[0].type !== 'reload';
+}
+
+function sendSync(message) {
+  if (!socket || socket.readyState !== WebSocket.OPEN) {
+    return;
+  }
+  message.page = window.location.pathname;
+  socket.send(JSON.stringify(message));
+}
+
+// scrollRatio is the scroll position relative to the scrollable size, as devices differ in size
+function scrollRatio(position, scrollSize, viewportSize) {
+  const scrollable = scrollSize - viewportSize;
+  return scrollable > 0 ? position / scrollable : 0;
+}
+
+function startSync() {
+  let scrollQueued = false;
+  window.addEventListener('scroll', () => {
+    if (scrollQueued || Date.now() < remoteScrollUntil) {
+      return;
+    }
+    scrollQueued = true;
+    requestAnimationFrame(() => {
+      scrollQueued = false;
+      const root = document.documentElement;
+      sendSync({
+        type: 'scroll',
+        x: scrollRatio(window.scrollX, root.scrollWidth, window.innerWidth),
+        y: scrollRatio(window.scrollY, root.scrollHeight, window.innerHeight),
+      });
+    });
+  }, { passive: true });
+
+  // only events of the user are reported, not those dispatched when applying remote ones
+  document.addEventListener('click', (event) => {
+    // links are followed through the navigation of the page they lead to
+    if (!event.isTrusted || !(event.target instanceof Element) || event.target.closest('a[href]')) {
+      return;
+    }
+    sendSync({ type: 'click', path: elementPath(event.target) });
+  }, true);
+
+  const reportInput = (event) => {
+    const el = event.target;
+    if (!event.isTrusted || !('value' in el) || el.type === 'password' || el.type === 'file') {
+      return;
+    }
+    const message = { type: 'input', path: elementPath(el), value: el.value };
+    if (el.type === 'checkbox' || el.type === 'radio') {
+      message.checked = el.checked;
+    }
+    sendSync(message);
+  };
+  document.addEventListener('input', reportInput, true);
+  document.addEventListener('change', reportInput, true);
+}
+
+// applySync mirrors the interaction of another browser
+function applySync(message) {
+  if (message.type === 'navigate') {
+    let target;
+    try {
+      target = new URL(message.url, window.location.href);
+    } catch (e) {
+      return;
+    }
+    // only pages of this server are followed, never other sites or javascript: urls
+    if (target.origin !== window.location.origin) {
+      return;
+    }
+    if (message.url !== window.location.pathname + window.location.search + window.location.hash) {
+      try {
+        sessionStorage.setItem(followedKey, message.url);
+      } catch (e) {
+        console.warn('sws: failed to store followed navigation:', e);
+      }
+      window.location.assign(message.url);
+    }
+    return;
+  }
+  if (message.page !== window.location.pathname) {
+    return;
+  }
+
+  const root = document.documentElement;
+  let el;
+  switch (message.type) {
+    case 'scroll':
+      remoteScrollUntil = Date.now() + 100;
+      window.scrollTo(
+        message.x * Math.max(0, root.scrollWidth - window.innerWidth),
+        message.y * Math.max(0, root.scrollHeight - window.innerHeight));
+      break;
+    case 'click':
+      el = document.querySelector(message.path);
+      if (el) {
+        el.click();
+      }
+      break;
+    case 'input':
+      el = document.querySelector(message.path);
+      if (!el) {
+        break;
+      }
+      if ('checked' in message) {
+        el.checked = message.checked;
+      } else {
+        el.value = message.value;
+      }
+      el.dispatchEvent(new Event('input', { bubbles: true }));
+      el.dispatchEvent(new Event('change', { bubbles: true }));
+      break;
+  }
+}
+
+function startWebsocket() {
+  // Check if the WebSocket object is available in the current context
+  if (typeof WebSocket !== 'function') {
+    console.error('WebSocket is not supported by this browser.');
+    return;
+  }
+
+  // Establish a connection with the WebSocket server, on the host the page was loaded from
+  let wsUrl = (window.location.protocol === 'https:' ? 'wss://' : 'ws://') +
+    (wsPort === 0 ? window.location.host : window.location.hostname + ':' + wsPort) + wsPath;
+  if (wsToken !== '') {
+    wsUrl += '?sws_token=' + encodeURIComponent(wsToken);
+  }
+  socket = new WebSocket(wsUrl);
+  let reportNavigation = sync && userNavigated();
+
+  // Event handler for when the WebSocket connection is established
+  socket.addEventListener('open', function (event) {
+    console.log('Connected to the WebSocket server');
+    if (reportNavigation) {
+      // other browsers follow to the page this one navigated to
+      reportNavigation = false;
+      sendSync({ type: 'navigate', url: window.location.pathname + window.location.search + window.location.hash });
+    }
+  });
+
+  // Event handler for when a message is received from the server
+  socket.addEventListener('message', function (event) {
+    // sync messages are json objects, updates are paths of changed files
+    if (event.data.charAt(0) === '{') {
+      try {
+        applySync(JSON.parse(event.data));
+      } catch (e) {
+        console.warn('sws: failed to apply sync message:', e);
+      }
+      return;
+    }
+    console.log('Message from server:', event.data);
+    handleUpdate(event.data);
+  });
+
+  // Event handler for when the WebSocket connection is closed
+  socket.addEventListener('close', function (event) {
+    console.log('Disconnected from the WebSocket server');
+  });
+
+  // Event handler for when an error occurs with the WebSocket connection
+  socket.addEventListener('error', function (event) {
+    console.error('WebSocket error:', event);
+    console.error(event.message);
+  });
+}
+
+startWebsocket();
+if (sync) {
+  startSync();
+}`
@@ -0,0 +1,88 @@
+package livereload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DirWatcher reports changes to the files below a directory, by their url paths
+// relative to it, for servers which serve the directory as is.
+type DirWatcher struct {
+	root string
+}
+
+// NewDirWatcher returns a watcher of the files below root, including directories created later.
+func NewDirWatcher(root string) *DirWatcher {
+	return &DirWatcher{root: root}
+}
+
+func (dw *DirWatcher) Watch(ctx context.Context, notify func(urlPath string)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create fsnotify watcher: %v", err)
+	}
+	defer w.Close()
+
+	if err := dw.add(w, dw.root); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.Events:
+			if !ok {
+				return errors.New("fsnotify watcher event channel closed")
+			}
+
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if err := dw.add(w, ev.Name); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			if !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Remove) {
+				continue
+			}
+
+			rel, err := filepath.Rel(dw.root, ev.Name)
+			if err != nil {
+				continue
+			}
+			notify("/" + filepath.ToSlash(rel))
+		case err, ok := <-w.Errors:
+			if !ok {
+				return errors.New("fsnotify watcher error channel closed")
+			}
+			return err
+		}
+	}
+}
+
+// add watches dir and all directories below it.
+func (dw *DirWatcher) add(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if err := w.Add(p); err != nil {
+			return fmt.Errorf("failed to watch path: '%v', err: %v", p, err)
+		}
+		return nil
+	})
+}
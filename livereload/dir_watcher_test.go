@@ -0,0 +1,38 @@
+package livereload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirWatcher(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	t.Cleanup(cancel)
+
+	changes := make(chan string, 8)
+	go NewDirWatcher(root).Watch(ctx, func(urlPath string) {
+		changes <- urlPath
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	t.Run("it should report changes to files in created directories by url path", func(t *testing.T) {
+		os.Mkdir(filepath.Join(root, "blog"), 0o755)
+		time.Sleep(10 * time.Millisecond)
+		os.WriteFile(filepath.Join(root, "blog", "index.html"), []byte("<p>post</p>"), 0o644)
+
+		for {
+			select {
+			case got := <-changes:
+				if got == "/blog/index.html" {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatal("expected a change of '/blog/index.html'")
+			}
+		}
+	})
+}
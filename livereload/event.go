@@ -0,0 +1,28 @@
+package livereload
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	// ClientConnected is emitted when a browser connects to the websocket. Client is set.
+	ClientConnected EventType = "client_connected"
+	// ClientDisconnected is emitted when a browser disconnects from the websocket. Client is set.
+	ClientDisconnected EventType = "client_disconnected"
+	// UpdateSent is emitted when a change has been sent to the connected browsers. Path and Clients are set.
+	UpdateSent EventType = "update_sent"
+	// SendFailed is emitted when a change could not be sent to a browser, which is then disconnected.
+	// Client, Path and Err are set.
+	SendFailed EventType = "send_failed"
+)
+
+// Event is something which happened to the websocket clients, passed to the hook of WithEventHook.
+type Event struct {
+	Type EventType
+	// Client is the remote address of the browser.
+	Client string
+	// Path is the url path of the changed file.
+	Path string
+	// Clients is the amount of browsers a change was sent to.
+	Clients int
+	Err     error
+}
@@ -0,0 +1,116 @@
+package livereload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNoInjectionPoint is returned for html documents which end within a comment
+// or an element holding raw text, such as a script, where the injected script would not run.
+var ErrNoInjectionPoint = errors.New("no injection point found")
+
+// rawTextElements hold raw text until their end tag, so an injection point
+// can not be found after an unterminated one.
+var rawTextElements = map[string]bool{
+	"script":    true,
+	"style":     true,
+	"textarea":  true,
+	"title":     true,
+	"xmp":       true,
+	"iframe":    true,
+	"noembed":   true,
+	"noframes":  true,
+	"noscript":  true,
+	"plaintext": true,
+}
+
+// findInjectionPoint tokenizes the html document and returns the offset at which the
+// script tag is injected, which is the first one of: before the '</head>' end tag,
+// after the '<head>' start tag, after the '<body>' start tag or the end of the document.
+// Tag names are matched case insensitively, and comments or script contents never match.
+func findInjectionPoint(b []byte) (int, error) {
+	z := html.NewTokenizer(bytes.NewReader(b))
+	offset := 0
+	headStart, bodyStart := -1, -1
+	openRawText := ""
+	unterminatedComment := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if !errors.Is(z.Err(), io.EOF) {
+				return 0, fmt.Errorf("failed to tokenize html: %v", z.Err())
+			}
+			break
+		}
+
+		raw := len(z.Raw())
+		switch tt {
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch tag := string(name); {
+			case tag == "head" && headStart == -1:
+				headStart = offset + raw
+			case tag == "body" && bodyStart == -1:
+				bodyStart = offset + raw
+			case rawTextElements[tag]:
+				openRawText = tag
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == "head" {
+				return offset, nil
+			}
+			if string(name) == openRawText {
+				openRawText = ""
+			}
+		case html.CommentToken:
+			unterminatedComment = !commentTerminated(z.Raw())
+		default:
+			unterminatedComment = false
+		}
+		offset += raw
+	}
+
+	switch {
+	case headStart != -1:
+		return headStart, nil
+	case bodyStart != -1:
+		return bodyStart, nil
+	case openRawText != "", unterminatedComment:
+		// the script tag would end up as text of the element or comment
+		return 0, ErrNoInjectionPoint
+	}
+	return len(b), nil
+}
+
+func commentTerminated(raw []byte) bool {
+	if !bytes.HasPrefix(raw, []byte("<!--")) {
+		// bogus comments, such as '<?xml ...>', end at the first '>'
+		return bytes.HasSuffix(raw, []byte(">"))
+	}
+	return len(raw) >= len("<!-->") && (bytes.HasSuffix(raw, []byte("-->")) || bytes.HasSuffix(raw, []byte("--!>")))
+}
+
+func injectScript(b []byte, scriptTag string) ([]byte, error) {
+	idx, err := findInjectionPoint(b)
+	if err != nil {
+		return b, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(b) + len(scriptTag))
+	buf.Write(b[:idx])
+	buf.WriteString(scriptTag)
+	buf.Write(b[idx:])
+	return buf.Bytes(), nil
+}
+
+// Inject returns the html document b with the script tag of the client injected,
+// see ScriptTag. If no injection point is found, b is returned unchanged with ErrNoInjectionPoint.
+func (lr *LiveReload) Inject(b []byte) ([]byte, error) {
+	return injectScript(b, lr.scriptTag)
+}
@@ -0,0 +1,106 @@
+package livereload
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const mockHtml = `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title></title>
+    <link href="css/style.css" rel="stylesheet">
+  </head>
+  <body>
+
+  </body>
+</html>`
+
+func Test_injectScript(t *testing.T) {
+	const tag = "<script>sws</script>"
+	tests := []struct {
+		name    string
+		html    string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "it should inject before the closing head tag",
+			html: mockHtml,
+			want: strings.Replace(mockHtml, "  </head>", "  "+tag+"</head>", 1),
+		},
+		{
+			name: "it should match tags case insensitively",
+			html: "<!DOCTYPE HTML><HTML><HEAD><TITLE>Old</TITLE></HEAD><BODY></BODY></HTML>",
+			want: "<!DOCTYPE HTML><HTML><HEAD><TITLE>Old</TITLE>" + tag + "</HEAD><BODY></BODY></HTML>",
+		},
+		{
+			name: "it should skip closing head tags in comments",
+			html: "<html><head><!-- </head> --><title>t</title></head><body></body></html>",
+			want: "<html><head><!-- </head> --><title>t</title>" + tag + "</head><body></body></html>",
+		},
+		{
+			name: "it should skip closing head tags in scripts",
+			html: `<html><head><script>document.write("</head>")</script></head><body></body></html>`,
+			want: `<html><head><script>document.write("</head>")</script>` + tag + `</head><body></body></html>`,
+		},
+		{
+			name: "it should not confuse header elements with head",
+			html: "<body><header>Title</header></body>",
+			want: "<body>" + tag + "<header>Title</header></body>",
+		},
+		{
+			name: "it should inject after the head start tag if head is not closed",
+			html: `<!doctype html><html lang="en"><head data-x="1"><meta charset="utf-8"><title>t</title><p>Hi`,
+			want: `<!doctype html><html lang="en"><head data-x="1">` + tag + `<meta charset="utf-8"><title>t</title><p>Hi`,
+		},
+		{
+			name: "it should inject after the body start tag without head",
+			html: "<!doctype html>\n<title>Minimal</title>\n<BODY class=\"x\">\n<p>Hello</p>\n",
+			want: "<!doctype html>\n<title>Minimal</title>\n<BODY class=\"x\">" + tag + "\n<p>Hello</p>\n",
+		},
+		{
+			name: "it should append to fragments",
+			html: "<section>\n  <h2>Fragment</h2>\n</section>\n",
+			want: "<section>\n  <h2>Fragment</h2>\n</section>\n" + tag,
+		},
+		{
+			name: "it should inject into empty documents",
+			html: "",
+			want: tag,
+		},
+		{
+			name:    "it should fail on unterminated comments",
+			html:    "<section></section><!-- <head></head>",
+			wantErr: ErrNoInjectionPoint,
+		},
+		{
+			name:    "it should fail on unterminated scripts",
+			html:    "<div></div><script>const s = '</head>';",
+			wantErr: ErrNoInjectionPoint,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := injectScript([]byte(tt.html), tag)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr != nil {
+				if string(got) != tt.html {
+					t.Fatalf("expected html to be unchanged, got: %v", string(got))
+				}
+				return
+			}
+
+			if string(got) != tt.want {
+				t.Fatalf("expected: %q, got: %q", tt.want, string(got))
+			}
+		})
+	}
+}
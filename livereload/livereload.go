@@ -0,0 +1,258 @@
+// Package livereload reloads browsers when files change. It injects a client script into
+// html pages, which connects to a websocket and applies the changes it is notified of:
+// reloading the page, swapping images in place or morphing the DOM.
+//
+// It is what 'sws serve' is built on, and can be embedded in other go dev servers:
+//
+//	lr, err := livereload.New()
+//	if err != nil {
+//		return err
+//	}
+//	defer lr.Close()
+//	go lr.Watch(ctx, livereload.NewDirWatcher("public"))
+//	http.ListenAndServe(":8080", lr.Middleware(http.FileServer(http.Dir("public"))))
+package livereload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultScriptPath is the url path the client script is served on.
+	DefaultScriptPath = "/delta-streamer.js"
+	// DefaultWsPath is the url path the websocket is served on.
+	DefaultWsPath = "/delta-streamer-ws"
+)
+
+// LiveReload injects the client script into html pages and notifies the connected clients
+// of changed files. Create it with New.
+type LiveReload struct {
+	scriptPath   string
+	wsPath       string
+	wsPort       int
+	alwaysReload []string
+	wsToken      string
+	morph        bool
+	sync         bool
+	forceReload  bool
+	checkOrigin  func(r *http.Request) bool
+	logger       *slog.Logger
+	eventHook    func(Event)
+	script       []byte
+	scriptTag    string
+	updates      chan string
+	syncChan     chan syncMessage
+	clients      sync.Map
+	dispatchOnce sync.Once
+	closeOnce    sync.Once
+	done         chan struct{}
+}
+
+// Option configures optional behaviour of the LiveReload.
+type Option func(*LiveReload)
+
+// WithScriptPath serves the client script on the url path p, instead of DefaultScriptPath.
+func WithScriptPath(p string) Option {
+	return func(lr *LiveReload) {
+		lr.scriptPath = p
+	}
+}
+
+// WithWsPath serves the websocket on the url path p, instead of DefaultWsPath.
+func WithWsPath(p string) Option {
+	return func(lr *LiveReload) {
+		lr.wsPath = p
+	}
+}
+
+// WithWsPort makes the client connect to the websocket on port of the page's hostname,
+// for websockets served by another server than the pages. By default the client
+// connects to the host the page was loaded from.
+func WithWsPort(port int) Option {
+	return func(lr *LiveReload) {
+		lr.wsPort = port
+	}
+}
+
+// WithAlwaysReload makes browsers reload on changes to any file below the url path prefixes,
+// such as mock fixtures which are fetched by pages rather than loaded as pages.
+func WithAlwaysReload(prefixes ...string) Option {
+	return func(lr *LiveReload) {
+		lr.alwaysReload = append(lr.alwaysReload, prefixes...)
+	}
+}
+
+// WithWsToken makes the client pass token along when connecting
+// to the websocket, for servers which require authentication.
+func WithWsToken(token string) Option {
+	return func(lr *LiveReload) {
+		lr.wsToken = token
+	}
+}
+
+// WithMorph makes browsers fetch changed pages and morph the live DOM to match them,
+// instead of reloading. Pages still reload if their scripts changed.
+func WithMorph() Option {
+	return func(lr *LiveReload) {
+		lr.morph = true
+	}
+}
+
+// WithSync makes browsers report scrolling, navigation, clicks and form input,
+// and relays them to all other connected browsers, which mirror them.
+func WithSync() Option {
+	return func(lr *LiveReload) {
+		lr.sync = true
+	}
+}
+
+// WithForceReload makes browsers reload on changes to any file.
+func WithForceReload() Option {
+	return func(lr *LiveReload) {
+		lr.forceReload = true
+	}
+}
+
+// WithCheckOrigin replaces the check of the origin of websocket connections,
+// which by default only allows connections from pages of the same host.
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) Option {
+	return func(lr *LiveReload) {
+		lr.checkOrigin = checkOrigin
+	}
+}
+
+// WithLogger logs to logger, instead of discarding the logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(lr *LiveReload) {
+		lr.logger = logger
+	}
+}
+
+// WithEventHook calls hook with the events of the websocket clients, such as to report them.
+// It is called from the goroutines serving the clients, and should not block.
+func WithEventHook(hook func(Event)) Option {
+	return func(lr *LiveReload) {
+		lr.eventHook = hook
+	}
+}
+
+// New returns a LiveReload, configured by opts.
+func New(opts ...Option) (*LiveReload, error) {
+	lr := &LiveReload{
+		scriptPath: DefaultScriptPath,
+		wsPath:     DefaultWsPath,
+		updates:    make(chan string),
+		syncChan:   make(chan syncMessage, syncBuffer),
+		logger:     slog.New(slog.DiscardHandler),
+		eventHook:  func(Event) {},
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(lr)
+	}
+
+	for _, p := range []string{lr.scriptPath, lr.wsPath} {
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("invalid url path: '%v', expected it to start with '/'", p)
+		}
+	}
+	if lr.scriptPath == lr.wsPath {
+		return nil, fmt.Errorf("the script and websocket can not both be served on: '%v'", lr.scriptPath)
+	}
+	if lr.wsPort < 0 || lr.wsPort > 65535 {
+		return nil, fmt.Errorf("invalid websocket port: %v", lr.wsPort)
+	}
+
+	script, err := lr.renderScript()
+	if err != nil {
+		return nil, err
+	}
+	lr.script = script
+	lr.scriptTag = `<!-- This script has been injected by sws and allows hot reloads -->
+<script type="module" src="` + html.EscapeString(lr.scriptPath) + `"></script>`
+	return lr, nil
+}
+
+func (lr *LiveReload) renderScript() ([]byte, error) {
+	alwaysReload, err := json.Marshal(append([]string{}, lr.alwaysReload...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal always reload prefixes: %v", err)
+	}
+
+	wsToken, err := json.Marshal(lr.wsToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal websocket token: %v", err)
+	}
+
+	wsPath, err := json.Marshal(lr.wsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal websocket path: %v", err)
+	}
+	return fmt.Appendf(nil, clientSource, alwaysReload, wsToken, lr.morph, lr.sync, lr.forceReload, lr.wsPort, wsPath), nil
+}
+
+// Script returns the client script, which has to be served on ScriptPath.
+func (lr *LiveReload) Script() []byte {
+	return lr.script
+}
+
+// ScriptPath returns the url path the client script is served on.
+func (lr *LiveReload) ScriptPath() string {
+	return lr.scriptPath
+}
+
+// ScriptTag returns the html which loads the client script, as injected into pages.
+func (lr *LiveReload) ScriptTag() string {
+	return lr.scriptTag
+}
+
+// WsPath returns the url path the websocket is served on.
+func (lr *LiveReload) WsPath() string {
+	return lr.wsPath
+}
+
+// Watcher reports changed files by their url paths, such as '/index.html', until ctx is done.
+type Watcher interface {
+	Watch(ctx context.Context, notify func(urlPath string)) error
+}
+
+// WatcherFunc adapts a function to a Watcher.
+type WatcherFunc func(ctx context.Context, notify func(urlPath string)) error
+
+func (f WatcherFunc) Watch(ctx context.Context, notify func(urlPath string)) error {
+	return f(ctx, notify)
+}
+
+// Watch notifies the clients of the changes w reports, until ctx is done or w fails.
+func (lr *LiveReload) Watch(ctx context.Context, w Watcher) error {
+	// changes are dispatched even if no browser has connected yet,
+	// so that the watcher never blocks
+	lr.ensureDispatcher()
+	return w.Watch(ctx, lr.Notify)
+}
+
+// Notify sends the url path of a changed file to all connected clients,
+// which decide how to apply the change. It blocks until the change is dispatched,
+// and returns without effect once the LiveReload is closed.
+func (lr *LiveReload) Notify(urlPath string) {
+	lr.ensureDispatcher()
+	select {
+	case lr.updates <- urlPath:
+	case <-lr.done:
+	}
+}
+
+// Close disconnects all clients. Changes are no longer dispatched afterwards.
+func (lr *LiveReload) Close() error {
+	lr.closeOnce.Do(func() {
+		close(lr.done)
+	})
+	return nil
+}
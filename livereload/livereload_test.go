@@ -0,0 +1,113 @@
+package livereload
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_New(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "it should use the defaults"},
+		{name: "it should accept custom paths", opts: []Option{WithScriptPath("/_sws/client.js"), WithWsPath("/_sws/ws")}},
+		{name: "it should fail on relative paths", opts: []Option{WithWsPath("ws")}, wantErr: true},
+		{name: "it should fail on equal paths", opts: []Option{WithScriptPath("/sws"), WithWsPath("/sws")}, wantErr: true},
+		{name: "it should fail on invalid ports", opts: []Option{WithWsPort(70000)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr, err := New(tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+
+			if err == nil && !strings.Contains(lr.ScriptTag(), `src="`+lr.ScriptPath()+`"`) {
+				t.Fatalf("expected script tag to load: '%v', got: %v", lr.ScriptPath(), lr.ScriptTag())
+			}
+		})
+	}
+}
+
+func Test_Script(t *testing.T) {
+	script := func(t *testing.T, opts ...Option) string {
+		t.Helper()
+		lr, err := New(opts...)
+		if err != nil {
+			t.Fatalf("failed to create live reload: %v", err)
+		}
+		return string(lr.Script())
+	}
+
+	t.Run("it should format all verbs of the script", func(t *testing.T) {
+		if b := script(t, WithForceReload(), WithWsToken("token")); strings.Contains(b, "%!") {
+			t.Fatalf("expected no formatting errors in the script, got:\n%s", b)
+		}
+	})
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "it should set always reload prefixes",
+			opts: []Option{WithAlwaysReload("/__mocks__/")},
+			want: []string{`const alwaysReloadPrefixes = ["/__mocks__/"];`},
+		},
+		{
+			name: "it should connect to the host of the page by default",
+			want: []string{"const wsPort = 0;", `const wsPath = "/delta-streamer-ws";`},
+		},
+		{
+			name: "it should connect to the websocket port and path",
+			opts: []Option{WithWsPort(8080), WithWsPath("/ws")},
+			want: []string{"const wsPort = 8080;", `const wsPath = "/ws";`},
+		},
+		{
+			name: "it should set force reload",
+			opts: []Option{WithForceReload()},
+			want: []string{"const forceReload = true;"},
+		},
+		{
+			name: "it should only sync browsing if enabled",
+			want: []string{"const sync = false;"},
+		},
+		{
+			name: "it should sync browsing",
+			opts: []Option{WithSync()},
+			want: []string{"const sync = true;"},
+		},
+	}
+
+	for _, enabled := range []bool{false, true} {
+		var opts []Option
+		if enabled {
+			opts = append(opts, WithMorph())
+		}
+		tests = append(tests, struct {
+			name string
+			opts []Option
+			want []string
+		}{
+			name: fmt.Sprintf("it should set morph to %v", enabled),
+			opts: opts,
+			want: []string{fmt.Sprintf("const morph = %v;", enabled)},
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := script(t, tt.opts...)
+			for _, want := range tt.want {
+				if !strings.Contains(b, want) {
+					t.Fatalf("expected the script to contain: '%v'", want)
+				}
+			}
+		})
+	}
+}
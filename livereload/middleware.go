@@ -0,0 +1,125 @@
+package livereload
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Middleware serves the client script on ScriptPath and the websocket on WsPath, and
+// injects the client into the html responses of next. Responses of next are not compressed,
+// so that they can be injected into.
+func (lr *LiveReload) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case lr.scriptPath:
+			w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Write(lr.script)
+			return
+		case lr.wsPath:
+			lr.ServeWebsocket(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.Header.Del("Accept-Encoding")
+		if acceptsHTML(r) {
+			// the injected page differs from the one a conditional or range request is made for,
+			// other requests keep them, so that assets are still cached and media can seek
+			r.Header.Del("If-None-Match")
+			r.Header.Del("If-Modified-Since")
+			r.Header.Del("Range")
+		}
+		iw := &injectingWriter{ResponseWriter: w, lr: lr, path: r.URL.Path}
+		next.ServeHTTP(iw, r)
+		iw.finish()
+	})
+}
+
+// acceptsHTML reports if r accepts html, as navigations of browsers do.
+func acceptsHTML(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "text/html") {
+			return true
+		}
+	}
+	return false
+}
+
+// injectingWriter buffers successful html responses, to inject the client into them once complete.
+// Other responses are passed through.
+type injectingWriter struct {
+	http.ResponseWriter
+	lr          *LiveReload
+	path        string
+	wroteHeader bool
+	// buf holds the html response while it is written, nil for other responses
+	buf *bytes.Buffer
+}
+
+func (iw *injectingWriter) WriteHeader(status int) {
+	if iw.wroteHeader {
+		return
+	}
+	iw.wroteHeader = true
+
+	h := iw.Header()
+	if status == http.StatusOK && h.Get("Content-Encoding") == "" && strings.HasPrefix(h.Get("Content-Type"), "text/html") {
+		iw.buf = &bytes.Buffer{}
+		return
+	}
+	iw.ResponseWriter.WriteHeader(status)
+}
+
+func (iw *injectingWriter) Write(b []byte) (int, error) {
+	if !iw.wroteHeader {
+		if iw.Header().Get("Content-Type") == "" {
+			iw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		iw.WriteHeader(http.StatusOK)
+	}
+
+	if iw.buf != nil {
+		return iw.buf.Write(b)
+	}
+	return iw.ResponseWriter.Write(b)
+}
+
+// finish injects the client into a buffered response and writes it.
+func (iw *injectingWriter) finish() {
+	if iw.buf == nil {
+		return
+	}
+
+	b, err := iw.lr.Inject(iw.buf.Bytes())
+	if err != nil {
+		// the page is still served, it just won't live reload
+		iw.lr.logger.Warn("failed to inject live reload script, page will not live reload", "path", iw.path, "err", err)
+	}
+	iw.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	iw.Header().Del("ETag")
+	iw.ResponseWriter.WriteHeader(http.StatusOK)
+	iw.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through, unless the response is buffered to be injected into.
+func (iw *injectingWriter) Flush() {
+	if iw.buf != nil {
+		return
+	}
+	if f, ok := iw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (iw *injectingWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
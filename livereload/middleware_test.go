@@ -0,0 +1,98 @@
+package livereload
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "index.html"), []byte(mockHtml), 0o644)
+	os.WriteFile(filepath.Join(root, "app.js"), []byte("console.log('</head>');"), 0o644)
+	os.WriteFile(filepath.Join(root, "broken.html"), []byte("<div></div><!-- <head>"), 0o644)
+
+	lr, err := New()
+	if err != nil {
+		t.Fatalf("failed to create live reload: %v", err)
+	}
+	t.Cleanup(func() { lr.Close() })
+	h := lr.Middleware(http.FileServer(http.Dir(root)))
+
+	get := func(target string, header http.Header) (*http.Response, string) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		resp := rec.Result()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+
+	t.Run("it should inject the client into html responses", func(t *testing.T) {
+		resp, body := get("/", http.Header{"Accept-Encoding": {"gzip"}})
+		if want, _ := injectScript([]byte(mockHtml), lr.ScriptTag()); body != string(want) {
+			t.Fatalf("expected injected page, got: %v", body)
+		}
+
+		if got := resp.Header.Get("Content-Length"); got != strconv.Itoa(len(body)) {
+			t.Fatalf("expected content length: %v, got: %v", len(body), got)
+		}
+	})
+
+	t.Run("it should inject into conditional requests of pages", func(t *testing.T) {
+		resp, body := get("/", http.Header{
+			"Accept":            {"text/html,application/xhtml+xml,*/*;q=0.8"},
+			"If-Modified-Since": {"Mon, 02 Jan 2100 15:04:05 GMT"},
+		})
+		if resp.StatusCode != http.StatusOK || !strings.Contains(body, lr.ScriptTag()) {
+			t.Fatalf("expected injected page, got: %v %v", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("it should keep conditional and range requests of other files", func(t *testing.T) {
+		header := http.Header{"Accept": {"*/*"}, "If-Modified-Since": {"Mon, 02 Jan 2100 15:04:05 GMT"}}
+		if resp, _ := get("/app.js", header); resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected status not modified, got: %v", resp.StatusCode)
+		}
+
+		resp, body := get("/app.js", http.Header{"Range": {"bytes=0-6"}})
+		if resp.StatusCode != http.StatusPartialContent || body != "console" {
+			t.Fatalf("expected partial content: 'console', got: %v '%v'", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("it should pass other responses through", func(t *testing.T) {
+		if _, body := get("/app.js", nil); body != "console.log('</head>');" {
+			t.Fatalf("expected unchanged script, got: %v", body)
+		}
+
+		if resp, _ := get("/missing.html", nil); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected status not found, got: %v", resp.StatusCode)
+		}
+	})
+
+	t.Run("it should serve pages it fails to inject into unchanged", func(t *testing.T) {
+		if _, body := get("/broken.html", nil); body != "<div></div><!-- <head>" {
+			t.Fatalf("expected unchanged page, got: %v", body)
+		}
+	})
+
+	t.Run("it should serve the client script", func(t *testing.T) {
+		resp, body := get(DefaultScriptPath, nil)
+		if body != string(lr.Script()) {
+			t.Fatal("expected the client script")
+		}
+
+		if got := resp.Header.Get("Content-Type"); !strings.Contains(got, "javascript") {
+			t.Fatalf("expected javascript content type, got: %v", got)
+		}
+	})
+}
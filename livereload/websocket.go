@@ -0,0 +1,210 @@
+package livereload
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/gorilla/websocket"
+)
+
+// syncReadLimit is the maximum size of messages from clients, which are small json objects.
+const syncReadLimit = 64 << 10
+
+// syncBuffer is the number of sync messages which may wait for the dispatcher,
+// more are dropped, as only the latest scroll position matters anyway.
+const syncBuffer = 64
+
+// syncMessage is an interaction a client reports, to be relayed to all other clients.
+type syncMessage struct {
+	from string
+	data string
+}
+
+// syncEvent is the interaction of a browser, as the client reports it. Only its fields are relayed.
+type syncEvent struct {
+	Type string `json:"type"`
+	// Page is the url path of the page the interaction happened on
+	Page string `json:"page"`
+	// URL is the url navigated to, with its query and fragment
+	URL     string   `json:"url,omitempty"`
+	X       *float64 `json:"x,omitempty"`
+	Y       *float64 `json:"y,omitempty"`
+	Path    string   `json:"path,omitempty"`
+	Value   *string  `json:"value,omitempty"`
+	Checked *bool    `json:"checked,omitempty"`
+}
+
+// valid reports if the event is one the client applies. Navigations have to stay on the
+// server, so that other websocket clients can't send browsers to other sites or scripts.
+func (e syncEvent) valid() bool {
+	switch e.Type {
+	case "scroll", "click", "input":
+		return true
+	case "navigate":
+		return isRootRelative(e.URL)
+	}
+	return false
+}
+
+// isRootRelative reports if s is a url path starting at the root of the same origin.
+func isRootRelative(s string) bool {
+	// browsers treat backslashes as slashes and drop tabs and newlines, so '/\host' leaves the origin
+	if !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//") || strings.ContainsFunc(s, func(r rune) bool {
+		return r == '\\' || unicode.IsControl(r)
+	}) {
+		return false
+	}
+
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// client is a connected websocket, which the dispatcher sends messages to.
+type client struct {
+	send chan string
+	// gone is closed once the client stopped receiving messages
+	gone chan struct{}
+}
+
+// ServeWebsocket upgrades the request to a websocket, over which the client is notified
+// of changes until it disconnects. The handler has to be served on WsPath.
+func (lr *LiveReload) ServeWebsocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: lr.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied with an error status
+		lr.logger.Warn("failed to upgrade to websocket", "client", r.RemoteAddr, "err", err)
+		return
+	}
+	lr.HandleConn(conn)
+}
+
+// HandleConn notifies the client of ws of changes until it disconnects or the LiveReload is closed,
+// for servers which upgrade websocket connections themselves.
+func (lr *LiveReload) HandleConn(ws *websocket.Conn) {
+	c := &client{
+		send: make(chan string),
+		gone: make(chan struct{}),
+	}
+	name := "ws-" + fmt.Sprintf("%v", rand.Int())
+	lr.logger.Info("new websocket connection", "client", ws.RemoteAddr())
+	lr.eventHook(Event{Type: ClientConnected, Client: ws.RemoteAddr().String()})
+	lr.register(name, c)
+	if lr.sync {
+		go lr.readSync(ws, name)
+	}
+
+	for done := false; !done; {
+		select {
+		case <-lr.done:
+			done = true
+		case msg := <-c.send:
+			if err := ws.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				// exit on error
+				lr.logger.Error("ws: failed to send message via ws", "client", ws.RemoteAddr(), "err", err)
+				lr.eventHook(Event{Type: SendFailed, Client: ws.RemoteAddr().String(), Path: msg, Err: err})
+				done = true
+			}
+		}
+	}
+
+	close(c.gone)
+	lr.clients.Delete(name)
+	lr.logger.Info("websocket disconnected", "client", ws.RemoteAddr())
+	lr.eventHook(Event{Type: ClientDisconnected, Client: ws.RemoteAddr().String()})
+	if err := ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1005, "")); err != nil {
+		lr.logger.Error("ws-listener: got err when writeclosing", "name", name, "err", err)
+	}
+
+	if err := ws.Close(); err != nil {
+		lr.logger.Error("ws-listener: got err when closing", "name", name, "err", err)
+	}
+}
+
+func (lr *LiveReload) register(name string, c *client) {
+	lr.ensureDispatcher()
+	lr.logger.Debug("registering", "name", name)
+	lr.clients.Store(name, c)
+}
+
+// ensureDispatcher starts the dispatcher unless it is already running.
+func (lr *LiveReload) ensureDispatcher() {
+	lr.dispatchOnce.Do(func() {
+		go lr.dispatch()
+	})
+}
+
+// dispatch sends changes to all clients and relays sync messages, until the LiveReload is closed.
+func (lr *LiveReload) dispatch() {
+	for {
+		select {
+		case <-lr.done:
+			lr.logger.Debug("stopping dispatcher")
+			return
+		case msg := <-lr.syncChan:
+			lr.broadcast(msg.data, msg.from)
+		case urlPath := <-lr.updates:
+			lr.logger.Info("got update", "path", urlPath)
+			clients := lr.broadcast(urlPath, "")
+			lr.eventHook(Event{Type: UpdateSent, Path: urlPath, Clients: clients})
+		}
+	}
+}
+
+// broadcast sends msg to all clients other than the one named except, and returns how many it sent it to.
+func (lr *LiveReload) broadcast(msg, except string) int {
+	var sent int
+	lr.clients.Range(func(key, value any) bool {
+		if key == except {
+			return true
+		}
+
+		lr.logger.Debug("sending message", "name", key, "message", msg)
+		c := value.(*client)
+		select {
+		case c.send <- msg:
+			sent++
+		case <-c.gone:
+		case <-lr.done:
+			return false
+		}
+		return true
+	})
+	return sent
+}
+
+// readSync reads the sync messages of the client named name until the connection is closed.
+// Messages have to be json objects, which sets them apart from the file paths sent to clients,
+// of the known sync events.
+func (lr *LiveReload) readSync(ws *websocket.Conn, name string) {
+	ws.SetReadLimit(syncReadLimit)
+	for {
+		msgType, b, err := ws.ReadMessage()
+		if err != nil {
+			lr.logger.Debug("stopped reading sync messages", "name", name, "err", err)
+			return
+		}
+
+		var ev syncEvent
+		if msgType != websocket.TextMessage || json.Unmarshal(b, &ev) != nil || !ev.valid() {
+			lr.logger.Warn("ignoring invalid sync message", "client", ws.RemoteAddr())
+			continue
+		}
+
+		data, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+
+		select {
+		case lr.syncChan <- syncMessage{from: name, data: string(data)}:
+		default:
+			lr.logger.Debug("dropping sync message, dispatcher is busy", "name", name)
+		}
+	}
+}
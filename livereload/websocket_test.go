@@ -0,0 +1,209 @@
+package livereload
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestServeWebsocket(t *testing.T) {
+	setup := func(t *testing.T, clients int, opts ...Option) (*LiveReload, []*websocket.Conn) {
+		t.Helper()
+		lr, err := New(opts...)
+		if err != nil {
+			t.Fatalf("failed to create live reload: %v", err)
+		}
+
+		server := httptest.NewServer(lr.Middleware(nil))
+		var conns []*websocket.Conn
+		for range clients {
+			ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+DefaultWsPath, nil)
+			if err != nil {
+				t.Fatalf("Failed to connect to WebSocket: %v", err)
+			}
+			conns = append(conns, ws)
+		}
+		t.Cleanup(func() {
+			lr.Close()
+			for _, ws := range conns {
+				ws.Close()
+			}
+			server.Close()
+		})
+
+		// clients are registered after the handshake completes
+		deadline := time.Now().Add(time.Second)
+		for registered := 0; registered != clients; {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %v clients to register, got: %v", clients, registered)
+			}
+			registered = 0
+			lr.clients.Range(func(key, value any) bool {
+				registered++
+				return true
+			})
+		}
+		return lr, conns
+	}
+
+	read := func(conns []*websocket.Conn) chan string {
+		gotMsgChan := make(chan string, 8)
+		for i, ws := range conns {
+			go func() {
+				for {
+					_, msg, err := ws.ReadMessage()
+					if err != nil {
+						return
+					}
+					gotMsgChan <- fmt.Sprintf("%v:%s", i, msg)
+				}
+			}()
+		}
+		return gotMsgChan
+	}
+
+	t.Run("it should send notified paths", func(t *testing.T) {
+		lr, conns := setup(t, 1)
+		go lr.Notify("/index.html")
+
+		_, msg, err := conns[0].ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to receive message: %v", err)
+		}
+
+		if string(msg) != "/index.html" {
+			t.Fatalf("Expected '/index.html', got: %v", string(msg))
+		}
+	})
+
+	t.Run("it should handle multiple connections at once", func(t *testing.T) {
+		lr, conns := setup(t, 2)
+		gotMsgChan := read(conns)
+		go lr.Notify("/index.html")
+
+		got := map[string]bool{}
+		for len(got) != 2 {
+			select {
+			case <-time.After(time.Second):
+				t.Fatalf("failed to receive data from websocket, got: %v", got)
+			case msg := <-gotMsgChan:
+				got[msg] = true
+			}
+		}
+
+		if !got["0:/index.html"] || !got["1:/index.html"] {
+			t.Fatalf("expected both clients to receive the path, got: %v", got)
+		}
+	})
+
+	t.Run("it should disconnect clients on close", func(t *testing.T) {
+		lr, conns := setup(t, 1)
+		lr.Close()
+		conns[0].SetReadDeadline(time.Now().Add(time.Second))
+		if _, _, err := conns[0].ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNoStatusReceived) {
+			t.Fatalf("expected the websocket to be closed, got: %v", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			lr.Notify("/index.html")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected notify to return once closed")
+		}
+	})
+
+	t.Run("it should report events to the hook and log to the logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		got := make(chan Event, 8)
+		lr, conns := setup(t, 1,
+			WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+			WithEventHook(func(ev Event) { got <- ev }))
+		read(conns)
+		lr.Notify("/index.html")
+
+		want := []Event{
+			{Type: ClientConnected, Client: conns[0].LocalAddr().String()},
+			{Type: UpdateSent, Path: "/index.html", Clients: 1},
+		}
+		for _, w := range want {
+			select {
+			case ev := <-got:
+				if ev != w {
+					t.Fatalf("expected: %+v, got: %+v", w, ev)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected event: %+v", w)
+			}
+		}
+
+		if !strings.Contains(buf.String(), "new websocket connection") {
+			t.Fatalf("expected the connection to be logged, got: %v", buf.String())
+		}
+	})
+
+	t.Run("it should relay sync messages to the other clients", func(t *testing.T) {
+		_, conns := setup(t, 2, WithSync())
+		gotMsgChan := read(conns)
+		for _, msg := range []string{
+			"not json",
+			`{"type":"unknown","page":"/"}`,
+			`{"type":"navigate","page":"/","url":"https://example.com/"}`,
+			`{"type":"navigate","page":"/","url":"javascript:alert(1)"}`,
+			`{"type":"scroll","page":"/","x":0,"y":0.5,"extra":"dropped"}`,
+		} {
+			if err := conns[0].WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				t.Fatalf("failed to write sync message: %v", err)
+			}
+		}
+
+		want := `1:{"type":"scroll","page":"/","x":0,"y":0.5}`
+		select {
+		case <-time.After(time.Second):
+			t.Fatal("failed to receive relayed sync message")
+		case got := <-gotMsgChan:
+			if got != want {
+				t.Fatalf("expected: '%v', got: '%v'", want, got)
+			}
+		}
+
+		select {
+		case got := <-gotMsgChan:
+			t.Fatalf("expected no more messages, got: '%v'", got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func Test_isRootRelative(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "/blog/?page=2#top", want: true},
+		{url: "/", want: true},
+		{url: "blog/", want: false},
+		{url: "//example.com/", want: false},
+		{url: "/\\example.com/", want: false},
+		{url: "/\t/example.com/", want: false},
+		{url: "https://example.com/", want: false},
+		{url: "javascript:alert(1)", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("it should report %q as %v", tc.url, tc.want), func(t *testing.T) {
+			if got := isRootRelative(tc.url); got != tc.want {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
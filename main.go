@@ -9,6 +9,7 @@ import (
 	"github.com/pchchv/sws/cmd"
 	"github.com/pchchv/sws/helpers/ancli"
 	"github.com/pchchv/sws/helpers/shutdown"
+	"github.com/pchchv/sws/internal/events"
 )
 
 func printHelp(command cmd.Command, err error, printUsage cmd.UsagePrinter) int {
@@ -64,12 +65,23 @@ func run(ctx context.Context, args []string, parseArgs cmd.ArgParser) int {
 
 func main() {
 	ancli.Newline = true
-	ancli.SlogIt = true
-	ancli.SetupSlog()
+	rootFlags, args, err := cmd.ParseRootFlags(os.Args)
+	if err != nil {
+		ancli.PrintfErr("failed to parse flags: %v", err)
+		cmd.PrintUsage()
+		os.Exit(1)
+	}
+
+	if rootFlags.Output == "jsonl" {
+		ancli.Stdout = os.Stderr
+		events.Enable(os.Stdout)
+	}
+
+	ancli.SetupSlog(rootFlags.LogLevel, rootFlags.LogFormat == "json")
 	ctx, cancel := context.WithCancel(context.Background())
 	exitCodeChan := make(chan int, 1)
 	go func() {
-		exitCodeChan <- run(ctx, os.Args, cmd.Parse)
+		exitCodeChan <- run(ctx, args, cmd.Parse)
 		cancel()
 	}()
 
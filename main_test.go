@@ -131,11 +131,11 @@ func Test_printHelp_ExitCodes(t *testing.T) {
 
 func Test_printHelp_output(t *testing.T) {
 	t.Run("it should print cmd help on cmd.HelpfulError", func(t *testing.T) {
-		want := "hello here is helpful message"
+		helpMsg := "hello here is helpful message"
 		mCmd := MockCommand{
-			helpFunc: func() string { return want },
+			helpFunc: func() string { return helpMsg },
 		}
-		want = want + "\n"
+		want := helpMsg + "\n"
 		got := captureStdout(t, func(t *testing.T) {
 			t.Helper()
 			printHelp(mCmd, cmd.ErrHelpful, func() {})